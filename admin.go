@@ -40,6 +40,16 @@ func (a admincmd) Subcmds() subcmd.Map {
 			"-tenant", subcmd.Int64, 0, "tenant ID",
 		),
 		"tenant", a.doTenant, "manage tenants", nil,
+		"group", a.doGroup, "manage GitHub team to Slack user group mappings", subcmd.Params(
+			"-tenant", subcmd.Int64, 0, "tenant ID",
+		),
+		"job", a.doJob, "manage the job queue", nil,
+		"secret", a.doSecret, "manage a tenant's webhook and signing secrets", subcmd.Params(
+			"-tenant", subcmd.Int64, 0, "tenant ID",
+		),
+		"botrule", a.doBotRule, "manage rules for mirroring comments by bots", subcmd.Params(
+			"-tenant", subcmd.Int64, 0, "tenant ID",
+		),
 	)
 }
 
@@ -0,0 +1,95 @@
+package spreche
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/bobg/mid"
+	"github.com/bobg/subcmd/v2"
+	"github.com/pkg/errors"
+)
+
+func (a admincmd) doBotRule(ctx context.Context, tenantID int64, args []string) error {
+	return a.s.Tenants.WithTenant(ctx, tenantID, "", "", func(ctx context.Context, tenant *Tenant) error {
+		return subcmd.Run(ctx, botrulecmd{s: a.s, tenant: tenant}, args)
+	})
+}
+
+type botrulecmd struct {
+	s      *Service
+	tenant *Tenant
+}
+
+func (b botrulecmd) Subcmds() subcmd.Map {
+	return subcmd.Commands(
+		"allow", b.doAllow, "add a rule allowing matching bot comments", b.ruleParams(),
+		"deny", b.doDeny, "add a rule denying matching bot comments", b.ruleParams(),
+		"list", b.doList, "list bot rules", nil,
+		"remove", b.doRemove, "remove bot rules (given as rule-ID args)", nil,
+	)
+}
+
+func (botrulecmd) ruleParams() []subcmd.Param {
+	return subcmd.Params(
+		"-repo", subcmd.String, "", "repo as owner/name (default: any)",
+		"-bot", subcmd.String, "", "GitHub bot login, or Slack bot ID or username (default: any)",
+		"-event", subcmd.String, "", fmt.Sprintf("GitHub event type, or %s for Slack bots (default: any)", BotEventSlackMessage),
+		"-pattern", subcmd.String, "", "regular expression matching the comment body (default: any)",
+	)
+}
+
+func (b botrulecmd) doAllow(ctx context.Context, repo, bot, event, pattern string, _ []string) error {
+	return b.add(ctx, true, repo, bot, event, pattern)
+}
+
+func (b botrulecmd) doDeny(ctx context.Context, repo, bot, event, pattern string, _ []string) error {
+	return b.add(ctx, false, repo, bot, event, pattern)
+}
+
+func (b botrulecmd) add(ctx context.Context, allow bool, repo, bot, event, pattern string) error {
+	if pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errors.Wrapf(err, "compiling pattern %s", pattern)
+		}
+	}
+	rule := &BotRule{
+		TenantID:    b.tenant.TenantID,
+		Allow:       allow,
+		Repo:        repo,
+		Bot:         bot,
+		EventType:   event,
+		BodyPattern: pattern,
+	}
+	if err := b.s.BotRules.Add(ctx, rule); err != nil {
+		return errors.Wrap(err, "adding bot rule")
+	}
+
+	w := mid.ResponseWriter(ctx)
+	fmt.Fprintf(w, "New rule ID %d\n", rule.RuleID)
+	return nil
+}
+
+func (b botrulecmd) doList(ctx context.Context, _ []string) error {
+	return b.s.BotRules.Foreach(ctx, b.tenant.TenantID, func(rule *BotRule) error {
+		w := mid.ResponseWriter(ctx)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rule)
+	})
+}
+
+func (b botrulecmd) doRemove(ctx context.Context, args []string) error {
+	for _, arg := range args {
+		ruleID, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "parsing rule ID %s", arg)
+		}
+		if err = b.s.BotRules.Remove(ctx, b.tenant.TenantID, ruleID); err != nil {
+			return errors.Wrapf(err, "removing bot rule %d", ruleID)
+		}
+	}
+	return nil
+}
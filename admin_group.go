@@ -0,0 +1,90 @@
+package spreche
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bobg/mid"
+	"github.com/bobg/subcmd/v2"
+	"github.com/pkg/errors"
+)
+
+func (a admincmd) doGroup(ctx context.Context, tenantID int64, args []string) error {
+	return a.s.Tenants.WithTenant(ctx, tenantID, "", "", func(ctx context.Context, tenant *Tenant) error {
+		return subcmd.Run(ctx, groupcmd{s: a.s, tenant: tenant}, args)
+	})
+}
+
+type groupcmd struct {
+	s      *Service
+	tenant *Tenant
+}
+
+func (g groupcmd) Subcmds() subcmd.Map {
+	return subcmd.Commands(
+		"add", g.doAdd, "map a GitHub team to a Slack user group", subcmd.Params(
+			"-org", subcmd.String, "", "GitHub org",
+			"-team", subcmd.String, "", "GitHub team slug",
+			"-group", subcmd.String, "", "Slack user group ID",
+		),
+		"list", g.doList, "list team mappings", nil,
+		"remove", g.doRemove, "remove a team mapping", subcmd.Params(
+			"-org", subcmd.String, "", "GitHub org",
+			"-team", subcmd.String, "", "GitHub team slug",
+		),
+		"sync", g.doSync, "set Slack user group members from GitHub team members", subcmd.Params(
+			"-org", subcmd.String, "", "GitHub org (default: all mappings)",
+			"-team", subcmd.String, "", "GitHub team slug",
+		),
+	)
+}
+
+func (g groupcmd) doAdd(ctx context.Context, org, team, group string, _ []string) error {
+	if org == "" || team == "" || group == "" {
+		return fmt.Errorf("must specify -org, -team, and -group")
+	}
+	return g.s.Groups.Add(ctx, g.tenant.TenantID, &Group{
+		GHOrg:        org,
+		GHTeam:       team,
+		SlackGroupID: group,
+	})
+}
+
+func (g groupcmd) doList(ctx context.Context, _ []string) error {
+	return g.s.Groups.Foreach(ctx, g.tenant.TenantID, func(group *Group) error {
+		w := mid.ResponseWriter(ctx)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(group)
+	})
+}
+
+func (g groupcmd) doRemove(ctx context.Context, org, team string, _ []string) error {
+	return g.s.Groups.Remove(ctx, g.tenant.TenantID, org, team)
+}
+
+func (g groupcmd) doSync(ctx context.Context, org, team string, _ []string) error {
+	if org != "" {
+		group, err := g.s.Groups.ByGHTeam(ctx, g.tenant.TenantID, org, team)
+		if err != nil {
+			return errors.Wrapf(err, "looking up team %s/%s", org, team)
+		}
+		return g.s.SyncGroup(ctx, g.tenant, group)
+	}
+
+	var groups []*Group
+	err := g.s.Groups.Foreach(ctx, g.tenant.TenantID, func(group *Group) error {
+		groups = append(groups, group)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "listing team mappings")
+	}
+	for _, group := range groups {
+		if err = g.s.SyncGroup(ctx, g.tenant, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package spreche
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/bobg/mid"
+	"github.com/bobg/subcmd/v2"
+	"github.com/pkg/errors"
+)
+
+func (a admincmd) doJob(ctx context.Context, args []string) error {
+	return subcmd.Run(ctx, jobcmd{s: a.s}, args)
+}
+
+type jobcmd struct{ s *Service }
+
+func (jc jobcmd) Subcmds() subcmd.Map {
+	return subcmd.Commands(
+		"list", jc.doList, "list queued jobs", subcmd.Params(
+			"-state", subcmd.String, JobDead, "job state: pending, running, or dead",
+		),
+		"requeue", jc.doRequeue, "return dead jobs to the queue", nil,
+	)
+}
+
+func (jc jobcmd) doList(ctx context.Context, state string, _ []string) error {
+	return jc.s.Jobs.Foreach(ctx, state, func(job *Job) error {
+		w := mid.ResponseWriter(ctx)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(job)
+	})
+}
+
+func (jc jobcmd) doRequeue(ctx context.Context, args []string) error {
+	for _, arg := range args {
+		jobID, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "parsing job ID %s", arg)
+		}
+		err = jc.s.Jobs.Requeue(ctx, jobID)
+		if err != nil {
+			return errors.Wrapf(err, "requeueing job %d", jobID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package spreche
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/bobg/mid"
+	"github.com/bobg/subcmd/v2"
+	"github.com/pkg/errors"
+)
+
+func (a admincmd) doSecret(ctx context.Context, tenantID int64, args []string) error {
+	return a.s.Tenants.WithTenant(ctx, tenantID, "", "", func(ctx context.Context, tenant *Tenant) error {
+		return subcmd.Run(ctx, secretcmd{s: a.s, tenant: tenant}, args)
+	})
+}
+
+type secretcmd struct {
+	s      *Service
+	tenant *Tenant
+}
+
+func (sc secretcmd) Subcmds() subcmd.Map {
+	return subcmd.Commands(
+		"add", sc.doAdd, "add a secret (given as the single arg)", subcmd.Params(
+			"-kind", subcmd.String, "", fmt.Sprintf("kind of secret: %s or %s", SecretGHWebhook, SecretSlackSigning),
+		),
+		"list", sc.doList, "list secrets (without their values)", subcmd.Params(
+			"-kind", subcmd.String, "", "kind of secret (default: all)",
+		),
+		"retire", sc.doRetire, "remove secrets (given as secret-ID args)", nil,
+	)
+}
+
+func (sc secretcmd) doAdd(ctx context.Context, kind string, args []string) error {
+	if kind != SecretGHWebhook && kind != SecretSlackSigning {
+		return fmt.Errorf("-kind must be %s or %s", SecretGHWebhook, SecretSlackSigning)
+	}
+	if len(args) != 1 || args[0] == "" {
+		return fmt.Errorf("must specify exactly one secret")
+	}
+	secret := &Secret{
+		TenantID: sc.tenant.TenantID,
+		Kind:     kind,
+		Secret:   args[0],
+	}
+	if err := sc.s.Secrets.Add(ctx, secret); err != nil {
+		return errors.Wrap(err, "adding secret")
+	}
+
+	w := mid.ResponseWriter(ctx)
+	fmt.Fprintf(w, "New secret ID %d\n", secret.SecretID)
+	return nil
+}
+
+func (sc secretcmd) doList(ctx context.Context, kind string, _ []string) error {
+	return sc.s.Secrets.Foreach(ctx, sc.tenant.TenantID, kind, func(secret *Secret) error {
+		w := mid.ResponseWriter(ctx)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(secret)
+	})
+}
+
+func (sc secretcmd) doRetire(ctx context.Context, args []string) error {
+	for _, arg := range args {
+		secretID, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "parsing secret ID %s", arg)
+		}
+		if err = sc.s.Secrets.Retire(ctx, sc.tenant.TenantID, secretID); err != nil {
+			return errors.Wrapf(err, "retiring secret %d", secretID)
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/bobg/mid"
@@ -21,6 +22,8 @@ type tenantcmd struct{ s *Service }
 func (tc tenantcmd) Subcmds() subcmd.Map {
 	return subcmd.Commands(
 		"add", tc.doAdd, "add a tenant", subcmd.Params(
+			"-ghapp", subcmd.Int64, 0, "GitHub App ID",
+			"-ghsecret", subcmd.String, "", "GitHub App webhook secret (default: the server's)",
 			"-ghinst", subcmd.Int64, 0, "GitHub installation ID",
 			"-ghpriv", subcmd.String, "", "path to file containing GitHub private key",
 			"-ghapi", subcmd.String, "", "GitHub API URL",
@@ -30,20 +33,36 @@ func (tc tenantcmd) Subcmds() subcmd.Map {
 		"addto", tc.doAddTo, "add a GitHub repo and/or a Slack team to a tenant", subcmd.Params(
 			"-tenant", subcmd.Int64, 0, "tenant ID",
 		),
+		"complete", tc.doComplete, "activate a pending tenant by attaching it to a Slack team", subcmd.Params(
+			"-tenant", subcmd.Int64, 0, "tenant ID",
+			"-slacktoken", subcmd.String, "", "Slack token",
+			"-slacksecret", subcmd.String, "", "Slack app signing secret",
+		),
 		"list", tc.doList, "list tenants", nil,
+		"set", tc.doSet, "change tenant settings (given as name=value args)", subcmd.Params(
+			"-tenant", subcmd.Int64, 0, "tenant ID",
+		),
 	)
 }
 
-func (tc tenantcmd) doAdd(ctx context.Context, ghinst int64, ghprivfile, ghapi, ghupload, slacktoken string, args []string) error {
+func (tc tenantcmd) doAdd(ctx context.Context, ghapp int64, ghsecret string, ghinst int64, ghprivfile, ghapi, ghupload, slacktoken string, args []string) error {
+	if ghapp == 0 {
+		return fmt.Errorf("must specify -ghapp")
+	}
 	ghpriv, err := os.ReadFile(ghprivfile)
 	if err != nil {
 		return errors.Wrap(err, "reading privkey file")
 	}
+	ghapi, err = canonicalGHAPIURL(ghapi)
+	if err != nil {
+		return err
+	}
 	tenant := &Tenant{
 		GHInstallationID: ghinst,
 		GHPrivKey:        ghpriv,
 		GHAPIURL:         ghapi,
 		GHUploadURL:      ghupload,
+		GHAppID:          ghapp,
 		SlackToken:       slacktoken,
 	}
 
@@ -60,6 +79,13 @@ func (tc tenantcmd) doAdd(ctx context.Context, ghinst int64, ghprivfile, ghapi,
 		return errors.Wrap(err, "adding new tenant")
 	}
 
+	if ghsecret != "" {
+		err = tc.s.Secrets.Add(ctx, &Secret{TenantID: tenant.TenantID, Kind: SecretGHWebhook, Secret: ghsecret})
+		if err != nil {
+			return errors.Wrap(err, "adding webhook secret")
+		}
+	}
+
 	w := mid.ResponseWriter(ctx)
 	fmt.Fprintf(w, "New tenant ID %d\n", tenant.TenantID)
 	return nil
@@ -82,6 +108,42 @@ func (tc tenantcmd) doAddTo(ctx context.Context, tenantID int64, args []string)
 	return nil
 }
 
+func (tc tenantcmd) doComplete(ctx context.Context, tenantID int64, slacktoken, slacksecret string, _ []string) error {
+	if slacktoken == "" {
+		return fmt.Errorf("must specify -slacktoken")
+	}
+	if slacksecret == "" {
+		return fmt.Errorf("must specify -slacksecret")
+	}
+	return tc.s.Tenants.WithTenant(ctx, tenantID, "", "", func(ctx context.Context, tenant *Tenant) error {
+		if tenant.State != TenantPending {
+			return fmt.Errorf("tenant %d is %s, not %s", tenantID, tenant.State, TenantPending)
+		}
+
+		tenant.SlackToken = slacktoken
+		auth, err := tenant.SlackClient().AuthTestContext(ctx)
+		if err != nil {
+			return errors.Wrap(err, "checking Slack token")
+		}
+		if err = tc.s.Tenants.AddTeam(ctx, tenantID, auth.TeamID); err != nil {
+			return errors.Wrapf(err, "adding team ID %s to tenant", auth.TeamID)
+		}
+		err = tc.s.Secrets.Add(ctx, &Secret{TenantID: tenantID, Kind: SecretSlackSigning, Secret: slacksecret})
+		if err != nil {
+			return errors.Wrap(err, "adding signing secret")
+		}
+
+		tenant.State = TenantActive
+		if err = tc.s.Tenants.Update(ctx, tenant); err != nil {
+			return errors.Wrap(err, "updating tenant")
+		}
+
+		w := mid.ResponseWriter(ctx)
+		fmt.Fprintf(w, "Tenant %d is now active for Slack team %s (%s)\n", tenantID, auth.Team, auth.TeamID)
+		return nil
+	})
+}
+
 func (tc tenantcmd) doList(ctx context.Context, _ []string) error {
 	return tc.s.Tenants.Foreach(ctx, func(t *Tenant) error {
 		w := mid.ResponseWriter(ctx)
@@ -90,3 +152,41 @@ func (tc tenantcmd) doList(ctx context.Context, _ []string) error {
 		return enc.Encode(t)
 	})
 }
+
+func (tc tenantcmd) doSet(ctx context.Context, tenantID int64, args []string) error {
+	return tc.s.Tenants.WithTenant(ctx, tenantID, "", "", func(ctx context.Context, tenant *Tenant) error {
+		for _, arg := range args {
+			name, val, ok := strings.Cut(arg, "=")
+			if !ok {
+				return fmt.Errorf("setting %s is not in name=value form", arg)
+			}
+			var err error
+			switch name {
+			case "gh_app_id":
+				tenant.GHAppID, err = strconv.ParseInt(val, 10, 64)
+			case "defer_drafts":
+				tenant.DeferDrafts, err = strconv.ParseBool(val)
+			case "mark_resolved":
+				tenant.MarkResolved, err = strconv.ParseBool(val)
+			case "diff_context_lines":
+				tenant.DiffContextLines, err = strconv.Atoi(val)
+				if err == nil && tenant.DiffContextLines < 0 {
+					err = fmt.Errorf("must not be negative")
+				}
+			case "proxy_images":
+				tenant.ProxyImages, err = strconv.ParseBool(val)
+			case "delete_policy":
+				if val != DeletePolicyTombstone && val != DeletePolicyDelete {
+					err = fmt.Errorf("must be %s or %s", DeletePolicyTombstone, DeletePolicyDelete)
+				}
+				tenant.DeletePolicy = val
+			default:
+				return fmt.Errorf("unknown setting %s", name)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "parsing %s value %s", name, val)
+			}
+		}
+		return tc.s.Tenants.Update(ctx, tenant)
+	})
+}
@@ -0,0 +1,83 @@
+package spreche
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// archiveRequest is the payload of a JobArchive job.
+type archiveRequest struct {
+	TenantID  int64  `json:"tenant_id"`
+	ChannelID string `json:"channel_id"`
+}
+
+// scheduleArchive queues a job to archive the channel of a PR closed at closedAt,
+// after s.ArchiveDelay.
+// Since the job is in the JobStore, it survives restarts.
+func (s *Service) scheduleArchive(ctx context.Context, tenant *Tenant, channel *Channel, closedAt time.Time) error {
+	if s.ArchiveDelay <= 0 {
+		return nil
+	}
+	payload, err := json.Marshal(archiveRequest{TenantID: tenant.TenantID, ChannelID: channel.ChannelID})
+	if err != nil {
+		return errors.Wrap(err, "marshaling archive request")
+	}
+	job := &Job{
+		Kind:    JobArchive,
+		Payload: payload,
+		RunAt:   closedAt.Add(s.ArchiveDelay),
+	}
+	err = s.Jobs.Add(ctx, job)
+	return errors.Wrap(err, "scheduling channel archive")
+}
+
+// archiveChannel processes a JobArchive job.
+// It does nothing if the PR has been reopened in the meantime,
+// or if the channel is already archived.
+func (s *Service) archiveChannel(ctx context.Context, payload []byte) error {
+	var req archiveRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return errors.Wrap(err, "unmarshaling archive request")
+	}
+	return s.Tenants.WithTenant(ctx, req.TenantID, "", "", func(ctx context.Context, tenant *Tenant) error {
+		channel, err := s.Channels.ByChannelID(ctx, tenant.TenantID, req.ChannelID)
+		if err != nil {
+			return errors.Wrapf(err, "getting info for channel %s", req.ChannelID)
+		}
+		if channel.State == StateOpen || !channel.ArchivedAt.IsZero() {
+			return nil
+		}
+		if time.Since(channel.ClosedAt) < s.ArchiveDelay {
+			// The PR was reopened and closed again.
+			// A later job will handle it.
+			return nil
+		}
+
+		debugf("Archiving channel %s", channel.ChannelID)
+
+		sc := tenant.SlackClient()
+		if err = sc.ArchiveConversationContext(ctx, channel.ChannelID); err != nil {
+			return errors.Wrapf(err, "archiving channel %s", channel.ChannelID)
+		}
+		err = s.Channels.SetArchivedAt(ctx, tenant.TenantID, channel.ChannelID, time.Now())
+		return errors.Wrap(err, "recording channel archive time")
+	})
+}
+
+// unarchiveChannel unarchives a channel and rejoins it.
+func (s *Service) unarchiveChannel(ctx context.Context, tenant *Tenant, channel *Channel) error {
+	debugf("Unarchiving channel %s", channel.ChannelID)
+
+	sc := tenant.SlackClient()
+	if err := sc.UnArchiveConversationContext(ctx, channel.ChannelID); err != nil {
+		return errors.Wrapf(err, "unarchiving channel %s", channel.ChannelID)
+	}
+	if _, _, _, err := sc.JoinConversationContext(ctx, channel.ChannelID); err != nil {
+		return errors.Wrapf(err, "rejoining channel %s", channel.ChannelID)
+	}
+	err := s.Channels.SetArchivedAt(ctx, tenant.TenantID, channel.ChannelID, time.Time{})
+	return errors.Wrap(err, "clearing channel archive time")
+}
@@ -0,0 +1,135 @@
+package spreche
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+)
+
+// backfill imports a PR's existing issue comments, reviews, and review comments into its (new) channel,
+// in chronological order.
+// Each is recorded in the CommentStore so later replies stay in sync,
+// and so that backfilling again skips it.
+func (s *Service) backfill(ctx context.Context, tenant *Tenant, channel *Channel) error {
+	gh, err := tenant.GHClient()
+	if err != nil {
+		return errors.Wrap(err, "getting GitHub client")
+	}
+
+	type item struct {
+		when    time.Time
+		comment ghComment
+	}
+	var (
+		items       []item
+		reviewTimes = make(map[int64]time.Time) // review ID -> submission time, for reviews that get their own message
+	)
+
+	issueOpts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := gh.Issues.ListComments(ctx, channel.Owner, channel.Repo, channel.PR, issueOpts)
+		if err != nil {
+			return errors.Wrap(err, "listing issue comments")
+		}
+		for _, c := range comments {
+			items = append(items, item{when: c.GetCreatedAt(), comment: issueCommentToGHComment(c)})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		issueOpts.Page = resp.NextPage
+	}
+
+	reviewOpts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := gh.PullRequests.ListReviews(ctx, channel.Owner, channel.Repo, channel.PR, reviewOpts)
+		if err != nil {
+			return errors.Wrap(err, "listing reviews")
+		}
+		for _, r := range reviews {
+			if !reviewGetsMessage(r) {
+				continue
+			}
+			reviewTimes[r.GetID()] = r.GetSubmittedAt()
+			items = append(items, item{when: r.GetSubmittedAt(), comment: reviewToGHComment(r)})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		reviewOpts.Page = resp.NextPage
+	}
+
+	reviewCommentOpts := &github.PullRequestListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := gh.PullRequests.ListComments(ctx, channel.Owner, channel.Repo, channel.PR, reviewCommentOpts)
+		if err != nil {
+			return errors.Wrap(err, "listing review comments")
+		}
+		for _, c := range comments {
+			it := item{when: c.GetCreatedAt(), comment: reviewCommentToGHComment(c)}
+			// A review's comments are created before the review is submitted,
+			// but must be posted after it, in its thread.
+			if submitted, ok := reviewTimes[c.GetPullRequestReviewID()]; ok && it.when.Before(submitted) {
+				it.when = submitted
+			}
+			items = append(items, it)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		reviewCommentOpts.Page = resp.NextPage
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].when.Before(items[j].when)
+	})
+
+	for _, it := range items {
+		c := it.comment
+		if c.body == "" && c.state == "" {
+			continue
+		}
+		// An earlier, interrupted backfill may already have imported this.
+		_, err = s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, c.commentID)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return errors.Wrapf(err, "looking up comment %d", c.commentID)
+		}
+		skip, err := s.skipGHComment(ctx, tenant, channel.Owner+"/"+channel.Repo, c)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+		if c.inReplyTo != 0 {
+			// The comment being replied to may have been skipped.
+			// If so, post this one at top level.
+			_, err = s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, c.inReplyTo)
+			if errors.Is(err, ErrNotFound) {
+				c.inReplyTo = 0
+			} else if err != nil {
+				return errors.Wrap(err, "looking up in-reply-to comment")
+			}
+		}
+		if c.reviewID != 0 {
+			// Likewise the review.
+			_, err = s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, c.reviewID)
+			if errors.Is(err, ErrNotFound) {
+				c.reviewID = 0
+			} else if err != nil {
+				return errors.Wrap(err, "looking up review")
+			}
+		}
+		if err = s.postGHComment(ctx, tenant, channel, c); err != nil {
+			return errors.Wrapf(err, "posting comment %d", c.commentID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+)
+
+// By default, comments by bots are not mirrored between GitHub and Slack.
+// A tenant's bot rules can change that:
+// a bot's comment is mirrored if it matches some allow rule and no deny rule.
+// Spreche's own comments are never mirrored back,
+// whatever the rules say.
+
+// BotRuleStore is a persistent store for the rules deciding which bots' comments are mirrored.
+type BotRuleStore interface {
+	// Add adds a rule.
+	// On a successful return, the RuleID field of the object is populated with the new ID.
+	Add(context.Context, *BotRule) error
+
+	// Remove removes a tenant's rule.
+	// If there is no such rule it returns ErrNotFound.
+	Remove(ctx context.Context, tenantID, ruleID int64) error
+
+	// Foreach calls f on each of a tenant's rules,
+	// in the order they were added.
+	Foreach(ctx context.Context, tenantID int64, f func(*BotRule) error) error
+}
+
+type BotRule struct {
+	RuleID   int64 `json:"rule_id"`
+	TenantID int64 `json:"tenant_id"`
+	Allow    bool  `json:"allow"`
+
+	// The remaining fields limit the comments the rule matches.
+	// An empty field matches anything.
+
+	// Repo is the repo, as owner/name.
+	Repo string `json:"repo,omitempty"`
+
+	// Bot is a GitHub bot login (like dependabot[bot]),
+	// or a Slack bot ID or username.
+	Bot string `json:"bot,omitempty"`
+
+	// EventType is the type of the GitHub webhook event
+	// (issue_comment, pull_request_review, or pull_request_review_comment),
+	// or "message" for Slack bots.
+	EventType string `json:"event_type,omitempty"`
+
+	// BodyPattern is a regular expression matched against the comment body.
+	BodyPattern string `json:"body_pattern,omitempty"`
+}
+
+// Event type for Slack bots in a BotRule.
+const BotEventSlackMessage = "message"
+
+// matches tells whether a rule applies to a comment.
+// The bots arg is the bot's identifiers,
+// any of which may match the rule's Bot field.
+func (r *BotRule) matches(repo string, bots []string, eventType, body string) (bool, error) {
+	if r.Repo != "" && !strings.EqualFold(r.Repo, repo) {
+		return false, nil
+	}
+	if r.Bot != "" {
+		var found bool
+		for _, bot := range bots {
+			if bot != "" && strings.EqualFold(r.Bot, bot) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if r.EventType != "" && r.EventType != eventType {
+		return false, nil
+	}
+	if r.BodyPattern != "" {
+		re, err := regexp.Compile(r.BodyPattern)
+		if err != nil {
+			return false, errors.Wrapf(err, "compiling pattern of bot rule %d", r.RuleID)
+		}
+		if !re.MatchString(body) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// allowBot tells whether a bot's comment should be mirrored according to a tenant's rules.
+func (s *Service) allowBot(ctx context.Context, tenantID int64, repo string, bots []string, eventType, body string) (bool, error) {
+	var allow, deny bool
+	err := s.BotRules.Foreach(ctx, tenantID, func(r *BotRule) error {
+		ok, err := r.matches(repo, bots, eventType, body)
+		if err != nil || !ok {
+			return err
+		}
+		if r.Allow {
+			allow = true
+		} else {
+			deny = true
+		}
+		return nil
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "checking bot rules")
+	}
+	return allow && !deny, nil
+}
+
+// skipGHComment tells whether a GitHub comment should not be mirrored to Slack,
+// because it is by this app,
+// or by a bot not allowed by the tenant's rules.
+// The repo is given as owner/name.
+func (s *Service) skipGHComment(ctx context.Context, tenant *Tenant, repo string, c ghComment) (bool, error) {
+	if !c.isBot() {
+		return false, nil
+	}
+	login := c.user.GetLogin()
+	appLogin, err := s.ghAppLogin(ctx, tenant)
+	if err != nil {
+		return true, err
+	}
+	if strings.EqualFold(login, appLogin) {
+		return true, nil
+	}
+	ok, err := s.allowBot(ctx, tenant.TenantID, repo, []string{login}, c.eventType(), c.body)
+	return !ok, err
+}
+
+// skipSlackBot tells whether a Slack message by a bot should not be mirrored to GitHub,
+// because the bot is not allowed by the tenant's rules.
+// (Messages by this app are recognized earlier, with isOwnSlackBot.)
+// The repo is given as owner/name.
+func (s *Service) skipSlackBot(ctx context.Context, tenant *Tenant, repo, botID, botName, text string) (bool, error) {
+	if botID == "" {
+		return false, nil
+	}
+	ok, err := s.allowBot(ctx, tenant.TenantID, repo, []string{botID, botName}, BotEventSlackMessage, text)
+	return !ok, err
+}
+
+// isOwnSlackBot tells whether the Slack bot with the given ID is this app's,
+// in the given team.
+func (s *Service) isOwnSlackBot(ctx context.Context, tenant *Tenant, teamID, botID string) (bool, error) {
+	if botID == "" {
+		return false, nil
+	}
+	ownID, ok := s.bots.get(teamID)
+	if !ok {
+		resp, err := tenant.SlackClient().AuthTestContext(ctx)
+		if err != nil {
+			return false, errors.Wrap(err, "getting Slack bot identity")
+		}
+		ownID = resp.BotID
+		s.bots.put(teamID, ownID)
+	}
+	return botID == ownID, nil
+}
+
+// ghAppLogin gets the login of the bot user that acts for the tenant's GitHub App.
+func (s *Service) ghAppLogin(ctx context.Context, tenant *Tenant) (string, error) {
+	key := fmt.Sprintf("%s %d", tenant.GHAPIURL, tenant.GHAppID)
+	if login, ok := s.bots.get(key); ok {
+		return login, nil
+	}
+
+	atr, err := ghinstallation.NewAppsTransport(http.DefaultTransport, tenant.GHAppID, tenant.GHPrivKey)
+	if err != nil {
+		return "", errors.Wrap(err, "creating app transport for GitHub client")
+	}
+	atr.BaseURL = tenant.GHAPIURL
+	gh, err := github.NewEnterpriseClient(tenant.GHAPIURL, tenant.GHUploadURL, &http.Client{Transport: atr})
+	if err != nil {
+		return "", errors.Wrap(err, "creating GitHub app client")
+	}
+	app, _, err := gh.Apps.Get(ctx, "")
+	if err != nil {
+		return "", errors.Wrap(err, "getting GitHub app")
+	}
+	login := app.GetSlug() + "[bot]"
+	s.bots.put(key, login)
+	return login, nil
+}
+
+// botIdentities caches the identities of this app's bots:
+// Slack bot IDs keyed by team ID,
+// and GitHub App logins keyed by API URL and app ID.
+// They do not change,
+// so recognizing the app's own messages and comments needs no API calls after the first.
+// The zero value is an empty cache ready to use.
+type botIdentities struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+func (b *botIdentities) get(key string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id, ok := b.ids[key]
+	return id, ok
+}
+
+func (b *botIdentities) put(key, id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ids == nil {
+		b.ids = make(map[string]string)
+	}
+	b.ids[key] = id
+}
@@ -0,0 +1,88 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestBotRuleMatches(t *testing.T) {
+	cases := []struct {
+		rule      BotRule
+		repo      string
+		bots      []string
+		eventType string
+		body      string
+		want      bool
+	}{{
+		rule: BotRule{}, repo: "bobg/spreche", bots: []string{"codecov[bot]"}, eventType: "issue_comment", want: true,
+	}, {
+		rule: BotRule{Bot: "Codecov[bot]"}, repo: "bobg/spreche", bots: []string{"codecov[bot]"}, eventType: "issue_comment", want: true,
+	}, {
+		rule: BotRule{Bot: "dependabot[bot]"}, repo: "bobg/spreche", bots: []string{"codecov[bot]"}, eventType: "issue_comment", want: false,
+	}, {
+		rule: BotRule{Repo: "bobg/other"}, repo: "bobg/spreche", bots: []string{"codecov[bot]"}, eventType: "issue_comment", want: false,
+	}, {
+		rule: BotRule{EventType: "message", Bot: "deploybot"}, repo: "bobg/spreche", bots: []string{"B123", "deploybot"}, eventType: "message", want: true,
+	}, {
+		rule: BotRule{EventType: "pull_request_review"}, repo: "bobg/spreche", bots: []string{"codecov[bot]"}, eventType: "issue_comment", want: false,
+	}, {
+		rule: BotRule{BodyPattern: "^## Codecov Report"}, repo: "bobg/spreche", bots: []string{"codecov[bot]"}, eventType: "issue_comment", body: "## Codecov Report\n...", want: true,
+	}, {
+		rule: BotRule{BodyPattern: "^## Codecov Report"}, repo: "bobg/spreche", bots: []string{"codecov[bot]"}, eventType: "issue_comment", body: "Something else", want: false,
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got, err := tc.rule.matches(tc.repo, tc.bots, tc.eventType, tc.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOwnSlackBot(t *testing.T) {
+	const channelID = "C0PRCHAN"
+
+	s, api := newFakeService(t, channelID)
+
+	ctx := context.Background()
+
+	repo := &github.Repository{Owner: &github.User{Login: github.String("bobg")}, Name: github.String("spreche")}
+	if err := s.Channels.Add(ctx, 1, channelID, repo, 17, "1.000000"); err != nil {
+		t.Fatal(err)
+	}
+
+	tenant := s.Tenants.(fakeTenantStore).tenant
+	gh, err := tenant.GHClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = s.OnMessage(ctx, "T0TEAM", gh, &slackevents.MessageEvent{
+			Type:        "message",
+			BotID:       "B0SPRECHE",
+			Text:        "Comment from GitHub",
+			TimeStamp:   fmt.Sprintf("%d.000000", i+2),
+			Channel:     channelID,
+			ChannelType: "channel",
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := api.count("POST /slack/auth.test"); n != 1 {
+		t.Errorf("got %d auth.test calls, want 1", n)
+	}
+	if n := api.count("POST /api/v3/"); n != 0 {
+		t.Errorf("got %d GitHub posts, want 0", n)
+	}
+}
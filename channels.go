@@ -4,15 +4,42 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v45/github"
 )
 
 // ChannelStore is the type of a persistent store for Channels.
 type ChannelStore interface {
+	// Add adds a channel.
+	// A channel added with no status card (an empty prbodyTS) is pending setup
+	// (see Channel.SetupPending).
 	Add(ctx context.Context, tenantID int64, channelID string, repo *github.Repository, pr int, prbodyTS string) error
 	ByChannelID(context.Context, int64, string) (*Channel, error)
 	ByRepoPR(context.Context, int64, *github.Repository, int) (*Channel, error)
+
+	// SetPRBodyTS records the timestamp of a channel's status card (see Channel.PRBodyTS),
+	// which completes its setup.
+	SetPRBodyTS(ctx context.Context, tenantID int64, channelID, prBodyTS string) error
+
+	// SetBackfillPending records whether a channel still awaits the import of its PR's existing discussion
+	// (see Channel.BackfillPending).
+	SetBackfillPending(ctx context.Context, tenantID int64, channelID string, pending bool) error
+
+	// SetState records the state of a channel's PR (StateOpen, StateClosed, or StateMerged)
+	// and the time it was closed (zero for StateOpen).
+	SetState(ctx context.Context, tenantID int64, channelID, state string, closedAt time.Time) error
+
+	// SetArchivedAt records the time a channel was archived.
+	// A zero time means the channel is not archived.
+	SetArchivedAt(ctx context.Context, tenantID int64, channelID string, archivedAt time.Time) error
+
+	// ForeachByRepo calls f for each channel of a PR in the given repo.
+	ForeachByRepo(ctx context.Context, tenantID int64, owner, repo string, f func(*Channel) error) error
+
+	// RenameRepo changes the owner and repo of all channels for PRs in the given repo,
+	// after the repo is renamed or transferred.
+	RenameRepo(ctx context.Context, tenantID int64, oldOwner, oldRepo, newOwner, newRepo string) error
 }
 
 // Channel is information about a Slack channel and the GitHub PR it is associated with.
@@ -24,8 +51,33 @@ type Channel struct {
 
 	// PRBodyTS is the timestamp of the message in the channel containing the PR body.
 	PRBodyTS string
+
+	// SetupPending tells whether the channel has yet to get its topic, members, and status card.
+	SetupPending bool
+
+	// BackfillPending tells whether the PR's existing discussion
+	// has yet to be imported into the channel.
+	BackfillPending bool
+
+	// State is the state of the PR: StateOpen, StateClosed, or StateMerged.
+	State string
+
+	// ClosedAt is the time the PR was closed or merged.
+	// It is zero for an open PR.
+	ClosedAt time.Time
+
+	// ArchivedAt is the time the channel was archived.
+	// It is zero for a channel that is not archived.
+	ArchivedAt time.Time
 }
 
+// PR states.
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+	StateMerged = "merged"
+)
+
 // ChannelName computes a Slack channel name for the given GH repo and PR number.
 func ChannelName(repo *github.Repository, prnum int) string {
 	// xxx Sanitize strings - only a-z0-9 allowed, plus hyphen and underscore. N.B. no capitals!
@@ -0,0 +1,75 @@
+package spreche
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v45/github"
+)
+
+func TestEnsureChannelRetry(t *testing.T) {
+	const channelID = "C0PRCHAN"
+
+	s, api := newFakeService(t, channelID)
+
+	ctx := context.Background()
+	tenant := s.Tenants.(fakeTenantStore).tenant
+
+	// An earlier attempt created the channel but did not record it,
+	// and got as far as importing the first comment.
+	api.respond("POST /slack/conversations.create", map[string]any{"ok": false, "error": "name_taken"})
+	api.respond("POST /slack/conversations.list", map[string]any{
+		"ok": true,
+		"channels": []any{
+			map[string]any{"id": "C0OTHER", "name": "pr-bobg-spreche-16", "is_member": true},
+			map[string]any{"id": channelID, "name": "pr-bobg-spreche-17", "is_member": true},
+		},
+	})
+	api.respond("GET /api/v3/repos/bobg/spreche/issues/17/comments", []any{
+		map[string]any{"id": 101, "body": "First", "user": map[string]any{"login": "alice", "html_url": "https://github.com/alice"}, "html_url": "https://github.com/c", "created_at": "2022-08-01T00:00:00Z"},
+		map[string]any{"id": 102, "body": "Second", "user": map[string]any{"login": "alice", "html_url": "https://github.com/alice"}, "html_url": "https://github.com/c", "created_at": "2022-08-02T00:00:00Z"},
+	})
+	if err := s.Comments.Add(ctx, tenant.TenantID, channelID, "5.000000", 101, CommentKindIssueComment); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &github.Repository{
+		Owner:   &github.User{Login: github.String("bobg")},
+		Name:    github.String("spreche"),
+		HTMLURL: github.String("https://github.com/bobg/spreche"),
+	}
+	pr := &github.PullRequest{
+		Number:  github.Int(17),
+		Title:   github.String("Fix things"),
+		HTMLURL: github.String("https://github.com/bobg/spreche/pull/17"),
+		User:    &github.User{Login: github.String("bobg")},
+	}
+
+	for i := 0; i < 2; i++ {
+		var got *Channel
+		err := s.ensureChannel(ctx, tenant, repo, pr, true, func(channel *Channel) error {
+			got = channel
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ChannelID != channelID {
+			t.Errorf("got channel %s, want %s", got.ChannelID, channelID)
+		}
+		if got.PRBodyTS == "" || got.SetupPending || got.BackfillPending {
+			t.Errorf("got PRBodyTS %q, SetupPending %v, BackfillPending %v, want a status card and nothing pending", got.PRBodyTS, got.SetupPending, got.BackfillPending)
+		}
+	}
+
+	if n := api.count("POST /slack/conversations.create"); n != 1 {
+		t.Errorf("got %d channel creations, want 1", n)
+	}
+	// One status card and one comment (the one not already imported).
+	if n := api.count("POST /slack/chat.postMessage"); n != 2 {
+		t.Errorf("got %d messages posted, want 2", n)
+	}
+	if _, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channelID, 102); err != nil {
+		t.Errorf("looking up imported comment: %s", err)
+	}
+}
@@ -0,0 +1,335 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// CheckStore is a persistent store associating a PR's head commit
+// with the Slack message summarizing the CI checks on that commit.
+type CheckStore interface {
+	// BySHA gets the timestamp of the checks message for the given commit in a channel.
+	// If there is none it returns ErrNotFound.
+	// If the message is reserved but not yet posted it returns "".
+	BySHA(ctx context.Context, tenantID int64, channelID, sha string) (string, error)
+
+	// Reserve records that the checks message for a commit in a channel is about to be posted,
+	// unless there is already a record for it.
+	// It tells whether it made the record.
+	Reserve(ctx context.Context, tenantID int64, channelID, sha string) (bool, error)
+
+	// SetTimestamp records the timestamp of a reserved checks message once it is posted.
+	SetTimestamp(ctx context.Context, tenantID int64, channelID, sha, timestamp string) error
+
+	// Unreserve removes a reservation whose message could not be posted.
+	// It does nothing if the message's timestamp has been recorded.
+	Unreserve(ctx context.Context, tenantID int64, channelID, sha string) error
+}
+
+// Check outcomes, as summarized in Slack.
+const (
+	checkPassed  = "passed"
+	checkFailed  = "failed"
+	checkPending = "pending"
+	checkSkipped = "skipped"
+)
+
+// checkResult is the outcome of a single check run or commit status.
+type checkResult struct {
+	name, url string
+	outcome   string
+	detail    string
+}
+
+// maxCheckFailures is the number of failed checks listed individually in a checks message.
+const maxCheckFailures = 10
+
+func (s *Service) OnCheckRun(ctx context.Context, ev *github.CheckRunEvent) error {
+	return s.onCommitChecks(ctx, ev.Repo, ev.CheckRun.GetHeadSHA(), ev.CheckRun.PullRequests)
+}
+
+func (s *Service) OnCheckSuite(ctx context.Context, ev *github.CheckSuiteEvent) error {
+	return s.onCommitChecks(ctx, ev.Repo, ev.CheckSuite.GetHeadSHA(), ev.CheckSuite.PullRequests)
+}
+
+func (s *Service) OnStatus(ctx context.Context, ev *github.StatusEvent) error {
+	// Status events do not say which PRs they pertain to.
+	return s.onCommitChecks(ctx, ev.Repo, ev.GetSHA(), nil)
+}
+
+// onCommitChecks posts or updates the checks message for the given commit
+// in the channel of each tracked PR whose head it is.
+// If prs is empty, the PRs containing the commit are found with the GitHub API.
+// Commits that are not the head of a tracked PR are ignored.
+func (s *Service) onCommitChecks(ctx context.Context, repo *github.Repository, sha string, prs []*github.PullRequest) error {
+	if sha == "" {
+		return nil
+	}
+	return s.Tenants.WithTenant(ctx, 0, *repo.HTMLURL, "", func(ctx context.Context, tenant *Tenant) error {
+		debugf("In onCommitChecks, tenant ID %d", tenant.TenantID)
+
+		gh, err := tenant.GHClient()
+		if err != nil {
+			return errors.Wrap(err, "getting GitHub client")
+		}
+
+		var (
+			owner = repo.GetOwner().GetLogin()
+			name  = repo.GetName()
+		)
+
+		if len(prs) == 0 {
+			prs, _, err = gh.PullRequests.ListPullRequestsWithCommit(ctx, owner, name, sha, nil)
+			if err != nil {
+				return errors.Wrapf(err, "listing PRs with commit %s", sha)
+			}
+		}
+
+		var channels []*Channel
+		for _, pr := range prs {
+			if pr.GetHead().GetSHA() != sha {
+				continue
+			}
+			channel, err := s.Channels.ByRepoPR(ctx, tenant.TenantID, repo, pr.GetNumber())
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "getting channel for PR %d", pr.GetNumber())
+			}
+			if !channel.ArchivedAt.IsZero() {
+				continue
+			}
+			channels = append(channels, channel)
+		}
+		if len(channels) == 0 {
+			return nil
+		}
+
+		results, err := commitCheckResults(ctx, gh, owner, name, sha)
+		if err != nil {
+			return err
+		}
+		options := checksMsgOptions(repo, sha, results)
+
+		sc := tenant.SlackClient()
+
+		for _, channel := range channels {
+			// Jobs for check runs, check suites, and statuses on the same commit may run concurrently.
+			// Reserving the checks message before posting it
+			// ensures that only one of them posts it.
+			reserved, err := s.Checks.Reserve(ctx, tenant.TenantID, channel.ChannelID, sha)
+			if err != nil {
+				return errors.Wrapf(err, "reserving checks message for %s", sha)
+			}
+			if reserved {
+				ts, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, "", options...)
+				if err != nil {
+					if err2 := s.Checks.Unreserve(ctx, tenant.TenantID, channel.ChannelID, sha); err2 != nil {
+						log.Printf("Error unreserving checks message for %s: %s", sha, err2)
+					}
+					return errors.Wrap(err, "posting checks message")
+				}
+				err = s.Checks.SetTimestamp(ctx, tenant.TenantID, channel.ChannelID, sha, ts)
+				if err != nil {
+					return errors.Wrap(err, "recording checks message")
+				}
+				continue
+			}
+
+			ts, err := s.Checks.BySHA(ctx, tenant.TenantID, channel.ChannelID, sha)
+			if err != nil {
+				return errors.Wrapf(err, "looking up checks message for %s", sha)
+			}
+			if ts == "" {
+				// Another job is posting the message right now.
+				// Try again after it has,
+				// lest these (possibly newer) results be lost.
+				return notYetError{fmt.Errorf("checks message for %s is being posted", sha)}
+			}
+			_, _, _, err = sc.UpdateMessageContext(ctx, channel.ChannelID, ts, options...)
+			if err != nil {
+				return errors.Wrap(err, "updating checks message")
+			}
+		}
+
+		return nil
+	})
+}
+
+// commitCheckResults gets the outcomes of all check runs and commit statuses for a commit.
+func commitCheckResults(ctx context.Context, gh *github.Client, owner, repo, sha string) ([]checkResult, error) {
+	var results []checkResult
+
+	runOpts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		runs, resp, err := gh.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, runOpts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing check runs for %s", sha)
+		}
+		for _, run := range runs.CheckRuns {
+			results = append(results, checkRunResult(run))
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		runOpts.Page = resp.NextPage
+	}
+
+	statusOpts := &github.ListOptions{PerPage: 100}
+	for {
+		combined, resp, err := gh.Repositories.GetCombinedStatus(ctx, owner, repo, sha, statusOpts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting combined status for %s", sha)
+		}
+		for _, status := range combined.Statuses {
+			results = append(results, statusResult(status))
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		statusOpts.Page = resp.NextPage
+	}
+
+	return results, nil
+}
+
+func checkRunResult(run *github.CheckRun) checkResult {
+	result := checkResult{
+		name:    run.GetName(),
+		url:     run.GetHTMLURL(),
+		outcome: checkPending,
+	}
+	if result.url == "" {
+		result.url = run.GetDetailsURL()
+	}
+	if run.GetStatus() == "completed" {
+		switch conclusion := run.GetConclusion(); conclusion {
+		case "success":
+			result.outcome = checkPassed
+		case "neutral", "skipped":
+			result.outcome = checkSkipped
+		default:
+			// failure, cancelled, timed_out, action_required, stale
+			result.outcome = checkFailed
+			if conclusion != "failure" {
+				result.detail = strings.ReplaceAll(conclusion, "_", " ")
+			}
+		}
+	}
+	if result.outcome == checkFailed {
+		detail := run.GetOutput().GetTitle()
+		if detail == "" {
+			detail, _, _ = strings.Cut(strings.TrimSpace(run.GetOutput().GetSummary()), "\n")
+		}
+		if detail != "" {
+			if result.detail != "" {
+				result.detail += ": "
+			}
+			result.detail += detail
+		}
+	}
+	return result
+}
+
+func statusResult(status *github.RepoStatus) checkResult {
+	result := checkResult{
+		name: status.GetContext(),
+		url:  status.GetTargetURL(),
+	}
+	switch status.GetState() {
+	case "success":
+		result.outcome = checkPassed
+	case "pending":
+		result.outcome = checkPending
+	default:
+		// failure, error
+		result.outcome = checkFailed
+		result.detail = status.GetDescription()
+	}
+	return result
+}
+
+func checksMsgOptions(repo *github.Repository, sha string, results []checkResult) []slack.MsgOption {
+	shortSHA := sha
+	if len(shortSHA) > 7 {
+		shortSHA = shortSHA[:7]
+	}
+
+	byOutcome := make(map[string][]checkResult)
+	for _, r := range results {
+		byOutcome[r.outcome] = append(byOutcome[r.outcome], r)
+	}
+
+	var counts []string
+	for _, outcome := range []string{checkFailed, checkPending, checkPassed, checkSkipped} {
+		if n := len(byOutcome[outcome]); n > 0 {
+			counts = append(counts, fmt.Sprintf("%s %d %s", checkOutcomeEmoji(outcome), n, outcome))
+		}
+	}
+	if len(counts) == 0 {
+		counts = []string{"_no checks yet_"}
+	}
+	header := fmt.Sprintf("*Checks for <%s/commit/%s|`%s`>:* %s", repo.GetHTMLURL(), sha, shortSHA, strings.Join(counts, ", "))
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, header, false, false), nil, nil),
+	}
+
+	if failed := byOutcome[checkFailed]; len(failed) > 0 {
+		var lines []string
+		for i, r := range failed {
+			if i == maxCheckFailures {
+				lines = append(lines, fmt.Sprintf("_…and %d more_", len(failed)-i))
+				break
+			}
+			line := fmt.Sprintf("%s %s", checkOutcomeEmoji(checkFailed), checkLink(r))
+			if r.detail != "" {
+				line += ": " + slackEscape(r.detail)
+			}
+			lines = append(lines, line)
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil))
+	}
+
+	if pending := byOutcome[checkPending]; len(pending) > 0 {
+		var links []string
+		for _, r := range pending {
+			links = append(links, checkLink(r))
+		}
+		text := fmt.Sprintf("%s Waiting for %s", checkOutcomeEmoji(checkPending), strings.Join(links, ", "))
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, text, false, false)))
+	}
+
+	return []slack.MsgOption{
+		slack.MsgOptionDisableLinkUnfurl(),
+		slack.MsgOptionText(fmt.Sprintf("Checks for %s: %s", shortSHA, strings.Join(counts, ", ")), false),
+		slack.MsgOptionBlocks(blocks...),
+	}
+}
+
+func checkLink(r checkResult) string {
+	if r.url == "" {
+		return "*" + slackEscape(r.name) + "*"
+	}
+	return fmt.Sprintf("*<%s|%s>*", r.url, slackEscape(r.name))
+}
+
+func checkOutcomeEmoji(outcome string) string {
+	switch outcome {
+	case checkPassed:
+		return ":white_check_mark:"
+	case checkFailed:
+		return ":x:"
+	case checkPending:
+		return ":hourglass_flowing_sand:"
+	case checkSkipped:
+		return ":heavy_minus_sign:"
+	}
+	return ":grey_question:"
+}
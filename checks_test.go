@@ -0,0 +1,146 @@
+package spreche
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/slack-go/slack"
+)
+
+func TestCheckRunResult(t *testing.T) {
+	cases := []struct {
+		run  *github.CheckRun
+		want checkResult
+	}{{
+		run:  &github.CheckRun{Name: github.String("build"), HTMLURL: github.String("https://x/1"), Status: github.String("in_progress")},
+		want: checkResult{name: "build", url: "https://x/1", outcome: checkPending},
+	}, {
+		run:  &github.CheckRun{Name: github.String("build"), DetailsURL: github.String("https://ci/1"), Status: github.String("completed"), Conclusion: github.String("success")},
+		want: checkResult{name: "build", url: "https://ci/1", outcome: checkPassed},
+	}, {
+		run:  &github.CheckRun{Name: github.String("lint"), Status: github.String("completed"), Conclusion: github.String("skipped")},
+		want: checkResult{name: "lint", outcome: checkSkipped},
+	}, {
+		run: &github.CheckRun{
+			Name:       github.String("test"),
+			Status:     github.String("completed"),
+			Conclusion: github.String("failure"),
+			Output:     &github.CheckRunOutput{Title: github.String("3 tests failed")},
+		},
+		want: checkResult{name: "test", outcome: checkFailed, detail: "3 tests failed"},
+	}, {
+		run: &github.CheckRun{
+			Name:       github.String("test"),
+			Status:     github.String("completed"),
+			Conclusion: github.String("failure"),
+			Output:     &github.CheckRunOutput{Summary: github.String("\nFirst line\nSecond line")},
+		},
+		want: checkResult{name: "test", outcome: checkFailed, detail: "First line"},
+	}, {
+		run: &github.CheckRun{
+			Name:       github.String("deploy"),
+			Status:     github.String("completed"),
+			Conclusion: github.String("timed_out"),
+			Output:     &github.CheckRunOutput{Title: github.String("No response")},
+		},
+		want: checkResult{name: "deploy", outcome: checkFailed, detail: "timed out: No response"},
+	}, {
+		run:  &github.CheckRun{Name: github.String("deploy"), Status: github.String("completed"), Conclusion: github.String("cancelled")},
+		want: checkResult{name: "deploy", outcome: checkFailed, detail: "cancelled"},
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got := checkRunResult(tc.run)
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChecksMsgOptions(t *testing.T) {
+	repo := &github.Repository{HTMLURL: github.String("https://github.com/bobg/spreche")}
+
+	var failures []checkResult
+	for i := 0; i < maxCheckFailures+2; i++ {
+		failures = append(failures, checkResult{name: fmt.Sprintf("f%d", i), outcome: checkFailed})
+	}
+
+	cases := []struct {
+		results      []checkResult
+		wantText     string
+		wantBlocks   []string
+		unwantBlocks []string
+	}{{
+		wantText:   "Checks for 0123456: _no checks yet_",
+		wantBlocks: []string{"<https://github.com/bobg/spreche/commit/0123456789abcdef|`0123456`>"},
+	}, {
+		results: []checkResult{
+			{name: "build", outcome: checkPassed},
+			{name: "test", url: "https://ci/2", outcome: checkFailed, detail: "a < b"},
+			{name: "lint", outcome: checkPending},
+			{name: "docs", outcome: checkSkipped},
+		},
+		wantText:     "Checks for 0123456: :x: 1 failed, :hourglass_flowing_sand: 1 pending, :white_check_mark: 1 passed, :heavy_minus_sign: 1 skipped",
+		wantBlocks:   []string{"*<https://ci/2|test>*: a &lt; b", "Waiting for *lint*"},
+		unwantBlocks: []string{"*build*", "*docs*"},
+	}, {
+		results:      failures,
+		wantText:     fmt.Sprintf("Checks for 0123456: :x: %d failed", len(failures)),
+		wantBlocks:   []string{"*f0*", fmt.Sprintf("*f%d*", maxCheckFailures-1), "_…and 2 more_"},
+		unwantBlocks: []string{fmt.Sprintf("*f%d*", maxCheckFailures)},
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			options := checksMsgOptions(repo, "0123456789abcdef", tc.results)
+			_, values, err := slack.UnsafeApplyMsgOptions("token", "C123", "https://slack.com/api/", options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := values.Get("text"); got != tc.wantText {
+				t.Errorf("got text %q, want %q", got, tc.wantText)
+			}
+			blocks := blocksText(t, values.Get("blocks"))
+			for _, want := range tc.wantBlocks {
+				if !strings.Contains(blocks, want) {
+					t.Errorf("blocks %s do not contain %q", blocks, want)
+				}
+			}
+			for _, unwant := range tc.unwantBlocks {
+				if strings.Contains(blocks, unwant) {
+					t.Errorf("blocks %s contain %q", blocks, unwant)
+				}
+			}
+		})
+	}
+}
+
+// blocksText gets the text in the JSON encoding of some section and context blocks.
+func blocksText(t *testing.T, blocksJSON string) string {
+	var blocks []struct {
+		Text *struct {
+			Text string `json:"text"`
+		} `json:"text"`
+		Elements []struct {
+			Text string `json:"text"`
+		} `json:"elements"`
+	}
+	if err := json.Unmarshal([]byte(blocksJSON), &blocks); err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, block := range blocks {
+		if block.Text != nil {
+			texts = append(texts, block.Text.Text)
+		}
+		for _, elem := range block.Elements {
+			texts = append(texts, elem.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
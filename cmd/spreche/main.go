@@ -12,6 +12,7 @@ import (
 	"os/exec"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/bobg/mid"
 	"github.com/bobg/subcmd/v2"
@@ -46,24 +47,32 @@ func (maincmd) Subcmds() subcmd.Map {
 }
 
 type config struct {
-	AdminKey string `yaml:"admin_key"`
-	Certfile string
-	Database string
-	// GithubPrivateKeyFile string `yaml:"github_private_key_file"`
-	GithubSecret string `yaml:"github_secret"`
+	AdminKey             string        `yaml:"admin_key"`
+	ArchiveDelay         time.Duration `yaml:"archive_delay"`
+	Certfile             string
+	Database             string
+	GithubAppID          int64  `yaml:"github_app_id"`           // for tenants created from installation webhooks
+	GithubPrivateKeyFile string `yaml:"github_private_key_file"` // for tenants created from installation webhooks
+	GithubSecret         string `yaml:"github_secret"`
 	// GithubAPIURL         string `yaml:"github_api_url"`    // "https://api.github.com/" or "https://HOST/api/v3/"
 	// GithubUploadURL      string `yaml:"github_upload_url"` // "https://uploads.github.com/" or "https://HOST/api/uploads/"
 	Keyfile            string
 	Listen             string
 	SlackSigningSecret string `yaml:"slack_signing_secret"`
+	FallbackSecrets    bool   `yaml:"fallback_secrets"` // whether tenants without secrets of their own use github_secret and slack_signing_secret
+	UploadDir          string `yaml:"upload_dir"`       // where to store files shared in Slack, for embedding in GitHub comments
+	UploadURL          string `yaml:"upload_url"`       // public base URL for those files, which this server serves under /uploads/
+	Workers            int
 	// SlackToken           string `yaml:"slack_token"`
 }
 
 var defaultConfig = config{
-	Database: "sqlite3:spreche.db",
+	ArchiveDelay: 24 * time.Hour,
+	Database:     "sqlite3:spreche.db",
 	// GithubAPIURL:    "https://api.github.com/",
 	// GithubUploadURL: "https://uploads.github.com/",
-	Listen: ":3853",
+	Listen:  ":3853",
+	Workers: 4,
 }
 
 var portRegex = regexp.MustCompile(`:(\d+)$`)
@@ -85,6 +94,15 @@ func doServe(ctx context.Context, configPath string, ngrok bool, _ []string) err
 		AdminKey:           c.AdminKey,
 		GHSecret:           c.GithubSecret,
 		SlackSigningSecret: c.SlackSigningSecret,
+		FallbackSecrets:    c.FallbackSecrets,
+		ArchiveDelay:       c.ArchiveDelay,
+		GHAppID:            c.GithubAppID,
+	}
+	if c.GithubPrivateKeyFile != "" {
+		s.GHPrivKey, err = os.ReadFile(c.GithubPrivateKeyFile)
+		if err != nil {
+			return errors.Wrap(err, "reading GitHub private key file")
+		}
 	}
 
 	dbparts := strings.SplitN(c.Database, ":", 2)
@@ -99,8 +117,15 @@ func doServe(ctx context.Context, configPath string, ngrok bool, _ []string) err
 			return errors.Wrap(err, "opening database")
 		}
 		defer stores.Close()
+		s.BotRules = stores.BotRules
 		s.Channels = stores.Channels
+		s.Checks = stores.Checks
 		s.Comments = stores.Comments
+		s.Deliveries = stores.Deliveries
+		s.Groups = stores.Groups
+		s.Jobs = stores.Jobs
+		s.Reactions = stores.Reactions
+		s.Secrets = stores.Secrets
 		s.Tenants = stores.Tenants
 		s.Users = stores.Users
 
@@ -110,8 +135,15 @@ func doServe(ctx context.Context, configPath string, ngrok bool, _ []string) err
 			return errors.Wrap(err, "opening database")
 		}
 		defer stores.Close()
+		s.BotRules = stores.BotRules
 		s.Channels = stores.Channels
+		s.Checks = stores.Checks
 		s.Comments = stores.Comments
+		s.Deliveries = stores.Deliveries
+		s.Groups = stores.Groups
+		s.Jobs = stores.Jobs
+		s.Reactions = stores.Reactions
+		s.Secrets = stores.Secrets
 		s.Tenants = stores.Tenants
 		s.Users = stores.Users
 
@@ -122,6 +154,19 @@ func doServe(ctx context.Context, configPath string, ngrok bool, _ []string) err
 	mux := http.NewServeMux()
 	mux.Handle("/github", mid.Err(s.OnGHWebhook))
 	mux.Handle("/slack", mid.Err(s.OnSlackEvent))
+	mux.Handle("/slack/interaction", mid.Err(s.OnSlackInteraction))
+
+	if c.UploadDir != "" {
+		if c.UploadURL == "" {
+			return fmt.Errorf("upload_dir requires upload_url")
+		}
+		if err = os.MkdirAll(c.UploadDir, 0755); err != nil {
+			return errors.Wrap(err, "creating upload dir")
+		}
+		uploader := spreche.DirUploader{Dir: c.UploadDir, BaseURL: c.UploadURL}
+		s.Uploads = uploader
+		mux.Handle("/uploads/", http.StripPrefix("/uploads/", uploader))
+	}
 
 	httpServer := &http.Server{
 		Addr:    c.Listen,
@@ -131,6 +176,15 @@ func doServe(ctx context.Context, configPath string, ngrok bool, _ []string) err
 
 	mux.Handle("/admin", mid.JSON(s.OnAdmin(httpServer, ch)))
 
+	workerCtx, cancelWorkers := context.WithCancel(ctx)
+	defer cancelWorkers()
+	go func() {
+		err := s.RunWorkers(workerCtx, c.Workers)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Error running job workers: %s", err)
+		}
+	}()
+
 	log.Printf("Listening on %s", httpServer.Addr)
 
 	if ngrok {
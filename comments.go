@@ -6,11 +6,41 @@ import "context"
 type CommentStore interface {
 	ByCommentID(ctx context.Context, tenantID int64, channelID string, commentID int64) (*Comment, error)
 	ByThreadTimestamp(ctx context.Context, tenantID int64, channelID, timestamp string) (*Comment, error)
-	Add(ctx context.Context, tenantID int64, channelID, timestamp string, commentID int64) error
+	Add(ctx context.Context, tenantID int64, channelID, timestamp string, commentID int64, kind string) error
 }
 
 type Comment struct {
 	ChannelID       string
 	ThreadTimestamp string
 	CommentID       int64
+
+	// Kind is one of the CommentKind constants,
+	// or "" for records made before the kind was recorded.
+	Kind string
+}
+
+// Kinds of GitHub comment.
+// CommentKindPRBody is for replies to the status card (see Channel.PRBodyTS),
+// which has no comment record.
+const (
+	CommentKindIssueComment  = "issue_comment"
+	CommentKindReview        = "review"
+	CommentKindReviewComment = "review_comment"
+	CommentKindPRBody        = "pr_body"
+)
+
+// tryCommentKinds calls f with the kind of a comment.
+// A comment record made before kinds were recorded
+// may be for an issue comment or a review comment,
+// so for such a record f is called with CommentKindIssueComment,
+// and again with CommentKindReviewComment if that gets a GitHub 404 response.
+func tryCommentKinds(comment *Comment, f func(kind string) error) error {
+	if comment.Kind != "" {
+		return f(comment.Kind)
+	}
+	err := f(CommentKindIssueComment)
+	if isGHNotFound(err) {
+		err = f(CommentKindReviewComment)
+	}
+	return err
 }
@@ -0,0 +1,69 @@
+package spreche
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DeliveryStore is a persistent record of the GitHub webhook deliveries and Slack events that have been processed,
+// so that redeliveries and retries can be ignored.
+type DeliveryStore interface {
+	// Add records a delivery ID for a tenant, to be forgotten after exp.
+	// It reports false if the ID is already recorded and has not yet expired.
+	Add(ctx context.Context, tenantID int64, deliveryID string, exp time.Time) (bool, error)
+
+	// Remove forgets a delivery ID.
+	Remove(ctx context.Context, tenantID int64, deliveryID string) error
+
+	// Expire forgets all delivery IDs whose expiration time is before now.
+	Expire(ctx context.Context, now time.Time) error
+}
+
+const (
+	deliveryTTL          = 7 * 24 * time.Hour
+	deliveryExpiryPeriod = time.Hour
+)
+
+// once calls f unless the given delivery has already been processed for the tenant.
+// If f fails, the delivery is forgotten so that a retry can process it.
+// An empty deliveryID means there is nothing to deduplicate on, and f is simply called.
+func (s *Service) once(ctx context.Context, tenantID int64, deliveryID string, f func() error) error {
+	if deliveryID == "" {
+		return f()
+	}
+	added, err := s.Deliveries.Add(ctx, tenantID, deliveryID, time.Now().Add(deliveryTTL))
+	if err != nil {
+		return errors.Wrapf(err, "recording delivery %s", deliveryID)
+	}
+	if !added {
+		debugf("Skipping already-processed delivery %s for tenant %d", deliveryID, tenantID)
+		return nil
+	}
+	if err = f(); err != nil {
+		if err2 := s.Deliveries.Remove(ctx, tenantID, deliveryID); err2 != nil {
+			log.Printf("Error forgetting failed delivery %s: %s", deliveryID, err2)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Service) expireDeliveries(ctx context.Context) {
+	ticker := time.NewTicker(deliveryExpiryPeriod)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Deliveries.Expire(ctx, time.Now()); err != nil {
+			log.Printf("Error expiring deliveries: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
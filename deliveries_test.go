@@ -0,0 +1,102 @@
+package spreche
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Webhook event types for the GitHub payloads in data/,
+// which were captured without their headers.
+var dataGHEventTypes = map[string]string{
+	"01-github-pr-created":               "pull_request",
+	"06-github-line-comment-added":       "pull_request_review_comment",
+	"07-github-line-comment-added-part2": "pull_request_review",
+	"10-github-threaded-reply-500":       "pull_request_review_comment",
+	"11-github-threaded-reply-part2":     "pull_request_review",
+}
+
+func TestReplayDeliveries(t *testing.T) {
+	s, api := newFakeService(t, "C03JBPHV2GM")
+
+	files, err := filepath.Glob("data/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jobs []*Job
+	for _, file := range files {
+		// Some files have notes trailing the JSON payload.
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload json.RawMessage
+		err = json.NewDecoder(f).Decode(&payload)
+		f.Close()
+		if err != nil {
+			t.Fatalf("decoding %s: %s", file, err)
+		}
+		basename := filepath.Base(file)
+		if strings.Contains(basename, "-github-") {
+			typ, ok := dataGHEventTypes[basename]
+			if !ok {
+				t.Fatalf("no event type known for %s", basename)
+			}
+			jobs = append(jobs, &Job{Kind: JobGitHub, EventType: typ, DeliveryID: "delivery-" + basename, Payload: payload})
+		} else {
+			var outer struct {
+				EventID string `json:"event_id"`
+			}
+			if err = json.Unmarshal(payload, &outer); err != nil {
+				t.Fatal(err)
+			}
+			jobs = append(jobs, &Job{Kind: JobSlack, DeliveryID: outer.EventID, Payload: payload})
+		}
+	}
+
+	ctx := context.Background()
+
+	replay := func() {
+		for _, job := range jobs {
+			if err := s.processJob(ctx, job); err != nil {
+				t.Fatalf("processing %s job %s: %s", job.Kind, job.DeliveryID, err)
+			}
+		}
+	}
+
+	sideEffects := []string{
+		"POST /slack/conversations.create",
+		"POST /slack/chat.postMessage",
+		"POST /slack/chat.update",
+		"POST /slack/chat.delete",
+		"POST /api/v3/repos/",
+	}
+	counts := func() map[string]int {
+		result := make(map[string]int)
+		for _, prefix := range sideEffects {
+			result[prefix] = api.count(prefix)
+		}
+		return result
+	}
+
+	replay()
+	first := counts()
+	if first["POST /slack/chat.postMessage"] == 0 {
+		t.Fatal("no messages posted to Slack on first replay")
+	}
+	if first["POST /api/v3/repos/"] == 0 {
+		t.Fatal("no comments posted to GitHub on first replay")
+	}
+
+	replay()
+	second := counts()
+	for _, prefix := range sideEffects {
+		if second[prefix] != first[prefix] {
+			t.Errorf("%s: %d requests after first replay, %d after second", prefix, first[prefix], second[prefix])
+		}
+	}
+}
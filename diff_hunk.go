@@ -0,0 +1,259 @@
+package spreche
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// hunkRef is the place in a PR's diff that a review comment refers to.
+type hunkRef struct {
+	diffHunk string
+	path     string
+	commitID string
+
+	// startLine and line are the range of commented lines, on the given side of the diff.
+	// For a single-line comment, startLine is zero.
+	startLine, line int
+
+	// side is LEFT (the base version) or RIGHT (the head version).
+	side string
+
+	// outdated is true when the comment's position no longer exists in the PR's current diff.
+	// The line numbers and commit are then the original ones.
+	outdated bool
+}
+
+// maxHunkChars is a limit on the size of a rendered diff hunk,
+// keeping it within Slack's limit for a section block.
+const maxHunkChars = 2800
+
+func reviewCommentHunkRef(c *github.PullRequestComment) *hunkRef {
+	if c.GetDiffHunk() == "" {
+		return nil
+	}
+	h := &hunkRef{
+		diffHunk:  c.GetDiffHunk(),
+		path:      c.GetPath(),
+		commitID:  c.GetCommitID(),
+		startLine: c.GetStartLine(),
+		line:      c.GetLine(),
+		side:      c.GetSide(),
+		outdated:  c.Position == nil,
+	}
+	if h.outdated {
+		h.commitID = c.GetOriginalCommitID()
+		h.startLine = c.GetOriginalStartLine()
+		h.line = c.GetOriginalLine()
+	}
+	if h.side == "" {
+		h.side = "RIGHT"
+	}
+	if h.startLine == h.line {
+		h.startLine = 0
+	}
+	return h
+}
+
+// refMrkdwn renders the path and line range of a hunkRef as Slack mrkdwn,
+// linking to them.
+// The commentURL is the HTML URL of the review comment.
+func (h *hunkRef) refMrkdwn(commentURL string) string {
+	var lines string
+	switch {
+	case h.line == 0:
+		// no line info
+	case h.startLine != 0:
+		lines = fmt.Sprintf(" lines %d–%d", h.startLine, h.line)
+	default:
+		lines = fmt.Sprintf(" line %d", h.line)
+	}
+	if lines != "" && h.side == "LEFT" {
+		lines += " (base)"
+	}
+
+	result := fmt.Sprintf("`%s`%s", slackEscape(h.path), lines)
+	if u := h.permalink(commentURL); u != "" {
+		result = fmt.Sprintf("<%s|%s>", u, result)
+	}
+	if h.outdated {
+		result += " _(outdated)_"
+	}
+	return result
+}
+
+// permalink produces a link to the commented lines.
+// For the head side of the diff that is the file at the comment's commit.
+// For the base side it is the PR's diff view,
+// since the base commit is not known here.
+func (h *hunkRef) permalink(commentURL string) string {
+	prURL, _, _ := strings.Cut(commentURL, "#")
+	if prURL == "" || h.path == "" {
+		return ""
+	}
+
+	var anchor string
+	if h.line != 0 {
+		if h.startLine != 0 {
+			anchor = fmt.Sprintf("L%d-L%d", h.startLine, h.line)
+		} else {
+			anchor = fmt.Sprintf("L%d", h.line)
+		}
+	}
+
+	if h.side == "RIGHT" && h.commitID != "" {
+		repoURL, _, ok := strings.Cut(prURL, "/pull/")
+		if ok {
+			u := fmt.Sprintf("%s/blob/%s/%s", repoURL, h.commitID, h.path)
+			if anchor != "" {
+				u += "#" + anchor
+			}
+			return u
+		}
+	}
+
+	// In the diff view, line anchors are L for the base side and R for the head side.
+	if h.side == "RIGHT" {
+		anchor = strings.ReplaceAll(anchor, "L", "R")
+	}
+	return fmt.Sprintf("%s/files#diff-%x%s", prURL, sha256.Sum256([]byte(h.path)), anchor)
+}
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// hunkLine is a line of a diff hunk.
+type hunkLine struct {
+	text string
+	num  int // line number on the hunkRef's side of the diff, or zero
+}
+
+// numberedLines splits the diff hunk into lines
+// (excluding the @@ header),
+// numbering them on h.side.
+func (h *hunkRef) numberedLines() []hunkLine {
+	rawLines := strings.Split(strings.TrimRight(h.diffHunk, "\n"), "\n")
+
+	var oldNum, newNum int
+	if m := hunkHeaderRegex.FindStringSubmatch(rawLines[0]); m != nil {
+		oldNum, _ = strconv.Atoi(m[1])
+		newNum, _ = strconv.Atoi(m[2])
+		rawLines = rawLines[1:]
+	}
+
+	lines := make([]hunkLine, 0, len(rawLines))
+	for _, l := range rawLines {
+		hl := hunkLine{text: l}
+		if oldNum > 0 || newNum > 0 {
+			var inOld, inNew bool
+			switch {
+			case strings.HasPrefix(l, "-"):
+				inOld = true
+			case strings.HasPrefix(l, "+"):
+				inNew = true
+			case strings.HasPrefix(l, `\`):
+				// "\ No newline at end of file"
+			default:
+				inOld, inNew = true, true
+			}
+			if h.side == "LEFT" && inOld {
+				hl.num = oldNum
+			} else if h.side != "LEFT" && inNew {
+				hl.num = newNum
+			}
+			if inOld {
+				oldNum++
+			}
+			if inNew {
+				newNum++
+			}
+		}
+		lines = append(lines, hl)
+	}
+	return lines
+}
+
+// commentedLines gives the text of the commented lines,
+// without their diff markers.
+// It returns false if they cannot all be found in the hunk.
+func (h *hunkRef) commentedLines() ([]string, bool) {
+	if h.line == 0 {
+		return nil, false
+	}
+	first := h.startLine
+	if first == 0 {
+		first = h.line
+	}
+	var result []string
+	for _, l := range h.numberedLines() {
+		if l.num >= first && l.num <= h.line && l.text != "" {
+			result = append(result, l.text[1:])
+		}
+	}
+	return result, len(result) == h.line-first+1
+}
+
+// codeMrkdwn renders the commented lines of the diff hunk,
+// preceded by up to contextLines lines of context,
+// as a Slack mrkdwn code block with line numbers.
+func (h *hunkRef) codeMrkdwn(contextLines int) string {
+	lines := h.numberedLines()
+	if len(lines) == 0 {
+		return ""
+	}
+
+	// The hunk ends at the (last) commented line.
+	last := len(lines) - 1
+	first := last
+	if h.startLine != 0 {
+		for i := last; i >= 0; i-- {
+			if lines[i].num == h.startLine {
+				first = i
+				break
+			}
+		}
+	}
+	first -= contextLines
+	if first < 0 {
+		first = 0
+	}
+	lines = lines[first:]
+
+	var width int
+	for _, l := range lines {
+		if n := len(strconv.Itoa(l.num)); l.num > 0 && n > width {
+			width = n
+		}
+	}
+
+	var rendered []string
+	for _, l := range lines {
+		var prefix string
+		if width > 0 {
+			num := ""
+			if l.num > 0 {
+				num = strconv.Itoa(l.num)
+			}
+			prefix = fmt.Sprintf("%*s ", width, num)
+		}
+		rendered = append(rendered, codeEscape(prefix+l.text))
+	}
+
+	// Drop lines from the top if necessary to stay within the size limit.
+	for len(rendered) > 1 && len(strings.Join(rendered, "\n")) > maxHunkChars {
+		rendered = rendered[1:]
+	}
+
+	return "```\n" + strings.Join(rendered, "\n") + "\n```"
+}
+
+// codeEscape escapes text for inclusion in a Slack mrkdwn code block.
+// In addition to the usual mrkdwn escaping,
+// each backtick is followed by a zero-width space,
+// so that no run of backticks in the text can close the block.
+func codeEscape(s string) string {
+	return strings.ReplaceAll(slackEscape(s), "`", "`\u200b")
+}
@@ -0,0 +1,66 @@
+package spreche
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func TestDiffHunk(t *testing.T) {
+	const (
+		hunk = "@@ -10,4 +10,5 @@ func f() {\n" +
+			" a := 1\n" +
+			"-b := `x`\n" +
+			"+b := \"x\"\n" +
+			"+c := b\n" +
+			" return a<b\n"
+		leftHunk = "@@ -10,2 +10,1 @@ func f() {\n" +
+			" a := 1\n" +
+			"-b := `x`"
+		commentURL = "https://github.com/o/r/pull/7#discussion_r1"
+	)
+
+	pathHash := fmt.Sprintf("%x", sha256.Sum256([]byte("f.go")))
+
+	cases := []struct {
+		name         string
+		h            hunkRef
+		contextLines int
+		wantCode     string
+		wantLink     string
+	}{{
+		name:         "single_line",
+		h:            hunkRef{diffHunk: hunk, path: "f.go", commitID: "abc", line: 13, side: "RIGHT"},
+		contextLines: 1,
+		wantCode:     "```\n12 +c := b\n13  return a&lt;b\n```",
+		wantLink:     "https://github.com/o/r/blob/abc/f.go#L13",
+	}, {
+		name:     "range",
+		h:        hunkRef{diffHunk: hunk, path: "f.go", commitID: "abc", startLine: 11, line: 13, side: "RIGHT"},
+		wantCode: "```\n11 +b := \"x\"\n12 +c := b\n13  return a&lt;b\n```",
+		wantLink: "https://github.com/o/r/blob/abc/f.go#L11-L13",
+	}, {
+		name:         "deleted_lines_unnumbered",
+		h:            hunkRef{diffHunk: hunk, path: "f.go", startLine: 10, line: 13, side: "RIGHT"},
+		contextLines: 0,
+		wantCode:     "```\n10  a := 1\n   -b := `\u200bx`\u200b\n11 +b := \"x\"\n12 +c := b\n13  return a&lt;b\n```",
+		wantLink:     "https://github.com/o/r/pull/7/files#diff-" + pathHash + "R10-R13",
+	}, {
+		name:         "left_side",
+		h:            hunkRef{diffHunk: leftHunk, path: "f.go", commitID: "abc", line: 11, side: "LEFT"},
+		contextLines: 5,
+		wantCode:     "```\n10  a := 1\n11 -b := `\u200bx`\u200b\n```",
+		wantLink:     "https://github.com/o/r/pull/7/files#diff-" + pathHash + "L11",
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.h.codeMrkdwn(c.contextLines); got != c.wantCode {
+				t.Errorf("got code:\n%s\nwant:\n%s", got, c.wantCode)
+			}
+			if got := c.h.permalink(commentURL); got != c.wantLink {
+				t.Errorf("got link %s, want %s", got, c.wantLink)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package spreche
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Hooks for the tests in package spreche_test,
+// which (unlike those in this package) can use the sqlite stores.
+
+// NewFakeAPI starts a fakeAPI (see newFakeAPI).
+// It returns the server's URL
+// and a function for counting the requests it has received (see fakeAPI.count).
+func NewFakeAPI(t *testing.T, channelID string) (url string, count func(string) int) {
+	api := newFakeAPI(t, channelID)
+	return api.URL, api.count
+}
+
+var FakePrivKey = fakePrivKey
+
+// RunJobs processes the runnable jobs in s.Jobs,
+// stopping at the first error.
+func (s *Service) RunJobs(ctx context.Context) error {
+	for {
+		job, err := s.Jobs.Claim(ctx, time.Now())
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "claiming job")
+		}
+		if err = s.processJob(ctx, job); err != nil {
+			return errors.Wrapf(err, "processing %s job %d", job.Kind, job.JobID)
+		}
+		if err = s.Jobs.Done(ctx, job.JobID); err != nil {
+			return errors.Wrapf(err, "removing job %d", job.JobID)
+		}
+	}
+}
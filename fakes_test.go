@@ -0,0 +1,431 @@
+package spreche
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// fakeAPI is an HTTP server standing in for both the Slack and GitHub APIs.
+// Slack methods are served under /slack/, GitHub endpoints under /api/v3/,
+// and GitHub-hosted images under /user-attachments/.
+// It records the requests it receives.
+type fakeAPI struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []string
+	nextID    int64
+	responses map[string]any // canned responses by "METHOD path"
+}
+
+// respond makes the fakeAPI answer requests with the given "METHOD path" string with resp.
+func (api *fakeAPI) respond(key string, resp any) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.responses == nil {
+		api.responses = make(map[string]any)
+	}
+	api.responses[key] = resp
+}
+
+// newFakeService produces a Service backed by in-memory stores,
+// with a single tenant whose Slack and GitHub clients talk to a fakeAPI.
+func newFakeService(t *testing.T, channelID string) (*Service, *fakeAPI) {
+	t.Helper()
+
+	api := newFakeAPI(t, channelID)
+
+	tenant := &Tenant{
+		TenantID:         1,
+		GHInstallationID: 1,
+		GHPrivKey:        fakePrivKey(t),
+		GHAPIURL:         api.URL + "/api/v3/",
+		GHUploadURL:      api.URL + "/api/uploads/",
+		SlackToken:       "xoxb-fake",
+	}
+
+	s := &Service{
+		BotRules:   fakeBotRuleStore{},
+		Channels:   &fakeChannelStore{},
+		Comments:   &fakeCommentStore{},
+		Deliveries: &fakeDeliveryStore{},
+		Tenants:    fakeTenantStore{tenant: tenant},
+		Users:      &fakeUserStore{},
+	}
+	return s, api
+}
+
+// newFakeAPI starts a fakeAPI and points the Slack client at it.
+// Its Slack conversations.create method creates the channel with the given ID.
+func newFakeAPI(t *testing.T, channelID string) *fakeAPI {
+	t.Helper()
+
+	api := &fakeAPI{nextID: 1000}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		api.serve(w, req, channelID)
+	}))
+	t.Cleanup(api.Close)
+
+	oldSlackAPIURL := slackAPIURL
+	slackAPIURL = api.URL + "/slack/"
+	t.Cleanup(func() { slackAPIURL = oldSlackAPIURL })
+
+	return api
+}
+
+// fakePrivKey generates a GitHub App private key.
+func fakePrivKey(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func (api *fakeAPI) serve(w http.ResponseWriter, req *http.Request, channelID string) {
+	api.mu.Lock()
+	api.requests = append(api.requests, req.Method+" "+req.URL.Path)
+	api.nextID++
+	id := api.nextID
+	canned, ok := api.responses[req.Method+" "+req.URL.Path]
+	api.mu.Unlock()
+
+	if ok {
+		json.NewEncoder(w).Encode(canned)
+		return
+	}
+
+	var resp any
+
+	switch path := req.URL.Path; {
+	case strings.HasPrefix(path, "/slack/"):
+		switch strings.TrimPrefix(path, "/slack/") {
+		case "conversations.create", "conversations.setTopic", "conversations.invite":
+			resp = map[string]any{"ok": true, "channel": map[string]any{"id": channelID}}
+		case "chat.postMessage", "chat.update":
+			resp = map[string]any{"ok": true, "channel": channelID, "ts": fmt.Sprintf("%d.000000", id)}
+		case "users.info":
+			resp = map[string]any{"ok": true, "user": map[string]any{"id": req.FormValue("user"), "name": "slackuser"}}
+		case "team.info":
+			resp = map[string]any{"ok": true, "team": map[string]any{"domain": "example"}}
+		case "auth.test":
+			resp = map[string]any{"ok": true, "bot_id": "B0SPRECHE", "team_id": "T0TEAM", "team": "Example"}
+		default:
+			resp = map[string]any{"ok": true}
+		}
+
+	case strings.HasPrefix(path, "/user-attachments/"):
+		// A GitHub-hosted image.
+		w.Write([]byte(fakePNG))
+		return
+
+	case strings.HasSuffix(path, "/access_tokens"):
+		w.WriteHeader(http.StatusCreated)
+		resp = map[string]any{"token": "ghs_fake", "expires_at": time.Now().Add(time.Hour)}
+
+	case req.Method == "POST":
+		w.WriteHeader(http.StatusCreated)
+		resp = map[string]any{"id": id}
+
+	case isListPath(path):
+		resp = []any{}
+
+	default:
+		resp = map[string]any{}
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// fakePNG is enough of a PNG file for http.DetectContentType.
+const fakePNG = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+// isListPath tells whether a GitHub API path names a collection
+// (like .../pulls/6/reviews)
+// rather than a single item
+// (like .../pulls/6).
+func isListPath(path string) bool {
+	last := path[strings.LastIndex(path, "/")+1:]
+	_, err := strconv.Atoi(last)
+	return err != nil
+}
+
+// count tells how many requests have been received whose "METHOD path" string has the given prefix.
+func (api *fakeAPI) count(prefix string) int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	var n int
+	for _, r := range api.requests {
+		if strings.HasPrefix(r, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+type fakeTenantStore struct {
+	tenant *Tenant // nil means no active tenant
+}
+
+func (f fakeTenantStore) WithTenant(ctx context.Context, _ int64, _, _ string, fn func(context.Context, *Tenant) error) error {
+	if f.tenant == nil {
+		return ErrNotFound
+	}
+	return fn(ctx, f.tenant)
+}
+
+func (f fakeTenantStore) Add(context.Context, *Tenant) error    { return nil }
+func (f fakeTenantStore) Update(context.Context, *Tenant) error { return nil }
+func (f fakeTenantStore) ByGHInstallation(context.Context, string, int64, int64) (*Tenant, error) {
+	return f.tenant, nil
+}
+func (f fakeTenantStore) AddGHURL(context.Context, int64, string) error            { return nil }
+func (f fakeTenantStore) AddTeam(context.Context, int64, string) error             { return nil }
+func (f fakeTenantStore) RemoveGHURL(context.Context, int64, string) error         { return nil }
+func (f fakeTenantStore) RenameGHURL(context.Context, int64, string, string) error { return nil }
+func (f fakeTenantStore) Foreach(_ context.Context, fn func(*Tenant) error) error {
+	return fn(f.tenant)
+}
+
+// fakeBotRuleStore has no rules.
+type fakeBotRuleStore struct{}
+
+func (fakeBotRuleStore) Add(context.Context, *BotRule) error                        { return nil }
+func (fakeBotRuleStore) Remove(context.Context, int64, int64) error                 { return ErrNotFound }
+func (fakeBotRuleStore) Foreach(context.Context, int64, func(*BotRule) error) error { return nil }
+
+type fakeChannelStore struct {
+	mu       sync.Mutex
+	channels []*Channel
+}
+
+func (f *fakeChannelStore) Add(_ context.Context, _ int64, channelID string, repo *github.Repository, pr int, prbodyTS string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.channels = append(f.channels, &Channel{
+		ChannelID:    channelID,
+		Owner:        repo.GetOwner().GetLogin(),
+		Repo:         repo.GetName(),
+		PR:           pr,
+		PRBodyTS:     prbodyTS,
+		SetupPending: prbodyTS == "",
+		State:        StateOpen,
+	})
+	return nil
+}
+
+func (f *fakeChannelStore) ByChannelID(_ context.Context, _ int64, channelID string) (*Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		if ch.ChannelID == channelID {
+			return ch, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeChannelStore) ByRepoPR(_ context.Context, _ int64, repo *github.Repository, pr int) (*Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		if ch.Owner == repo.GetOwner().GetLogin() && ch.Repo == repo.GetName() && ch.PR == pr {
+			return ch, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeChannelStore) SetState(_ context.Context, _ int64, channelID, state string, closedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		if ch.ChannelID == channelID {
+			ch.State, ch.ClosedAt = state, closedAt
+		}
+	}
+	return nil
+}
+
+func (f *fakeChannelStore) SetPRBodyTS(_ context.Context, _ int64, channelID, prBodyTS string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		if ch.ChannelID == channelID {
+			ch.PRBodyTS, ch.SetupPending = prBodyTS, false
+		}
+	}
+	return nil
+}
+
+func (f *fakeChannelStore) SetBackfillPending(_ context.Context, _ int64, channelID string, pending bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		if ch.ChannelID == channelID {
+			ch.BackfillPending = pending
+		}
+	}
+	return nil
+}
+
+func (f *fakeChannelStore) SetArchivedAt(_ context.Context, _ int64, channelID string, archivedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		if ch.ChannelID == channelID {
+			ch.ArchivedAt = archivedAt
+		}
+	}
+	return nil
+}
+
+func (f *fakeChannelStore) ForeachByRepo(_ context.Context, _ int64, owner, repo string, fn func(*Channel) error) error {
+	f.mu.Lock()
+	var channels []*Channel
+	for _, ch := range f.channels {
+		if ch.Owner == owner && ch.Repo == repo {
+			channels = append(channels, ch)
+		}
+	}
+	f.mu.Unlock()
+	for _, ch := range channels {
+		if err := fn(ch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeChannelStore) RenameRepo(_ context.Context, _ int64, oldOwner, oldRepo, newOwner, newRepo string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		if ch.Owner == oldOwner && ch.Repo == oldRepo {
+			ch.Owner, ch.Repo = newOwner, newRepo
+		}
+	}
+	return nil
+}
+
+type fakeCommentStore struct {
+	mu       sync.Mutex
+	comments []*Comment
+}
+
+func (f *fakeCommentStore) ByCommentID(_ context.Context, _ int64, channelID string, commentID int64) (*Comment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.comments {
+		if c.ChannelID == channelID && c.CommentID == commentID {
+			return c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeCommentStore) ByThreadTimestamp(_ context.Context, _ int64, channelID, timestamp string) (*Comment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.comments {
+		if c.ChannelID == channelID && c.ThreadTimestamp == timestamp {
+			return c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeCommentStore) Add(_ context.Context, _ int64, channelID, timestamp string, commentID int64, kind string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.comments = append(f.comments, &Comment{ChannelID: channelID, ThreadTimestamp: timestamp, CommentID: commentID, Kind: kind})
+	return nil
+}
+
+type fakeDeliveryStore struct {
+	mu         sync.Mutex
+	deliveries map[string]time.Time
+}
+
+func (f *fakeDeliveryStore) Add(_ context.Context, tenantID int64, deliveryID string, exp time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.deliveries == nil {
+		f.deliveries = make(map[string]time.Time)
+	}
+	key := fmt.Sprintf("%d/%s", tenantID, deliveryID)
+	if oldExp, ok := f.deliveries[key]; ok && !oldExp.Before(time.Now()) {
+		return false, nil
+	}
+	f.deliveries[key] = exp
+	return true, nil
+}
+
+func (f *fakeDeliveryStore) Remove(_ context.Context, tenantID int64, deliveryID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.deliveries, fmt.Sprintf("%d/%s", tenantID, deliveryID))
+	return nil
+}
+
+func (f *fakeDeliveryStore) Expire(_ context.Context, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, exp := range f.deliveries {
+		if exp.Before(now) {
+			delete(f.deliveries, key)
+		}
+	}
+	return nil
+}
+
+type fakeUserStore struct {
+	mu    sync.Mutex
+	users []*User
+}
+
+func (f *fakeUserStore) BySlackID(_ context.Context, _ int64, slackID string) (*User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users {
+		if u.SlackID == slackID {
+			return u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeUserStore) ByGHLogin(_ context.Context, _ int64, ghLogin string) (*User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users {
+		if u.GHLogin == ghLogin {
+			return u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeUserStore) Add(_ context.Context, _ int64, u *User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users = append(f.users, u)
+	return nil
+}
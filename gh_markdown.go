@@ -14,7 +14,7 @@ import (
 	"golang.org/x/net/html"
 )
 
-func ghMarkdownToSlack(inp []byte) []slack.Block {
+func ghMarkdownToSlack(inp []byte, users *userMap, refs *ghRefs) []slack.Block {
 	md := markdown.New() // xxx options?
 	tokens := md.Parse(inp)
 	blocks := ghTokensToSlackBlocks(tokens)
@@ -24,6 +24,11 @@ func ghMarkdownToSlack(inp []byte) []slack.Block {
 			continue
 		}
 
+		for _, elem := range rblock.Elements {
+			ghRefsToSlack(elem, refs)
+			ghMentionsToSlack(elem, users)
+		}
+
 		// Cannot use rich-text blocks on input to the Slack API (yet?).
 		// See: https://github.com/slackapi/java-slack-sdk/issues/876#issuecomment-956557504
 		// Downconvert them to TextBlockObjects with mrkdwn.
@@ -559,7 +564,7 @@ func richTextSectionElementToMrkdwn(w io.Writer, elem slack.RichTextSectionEleme
 		richTextStrToMrkdwn(w, elem.ChannelID, elem.Style)
 
 	case *slack.RichTextSectionUserElement:
-		richTextStrToMrkdwn(w, elem.UserID, elem.Style)
+		richTextStrToMrkdwn(w, "<@"+elem.UserID+">", elem.Style)
 
 	case *slack.RichTextSectionEmojiElement:
 		richTextStrToMrkdwn(w, elem.Name, elem.Style)
@@ -609,6 +614,13 @@ func richTextStrToMrkdwn(w io.Writer, str string, style *slack.RichTextSectionTe
 	fmt.Fprint(w, str)
 }
 
+// slackEscape escapes the characters that are special in Slack mrkdwn text.
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
 type lineWriter struct {
 	w        io.Writer
 	anyBytes bool
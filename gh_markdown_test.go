@@ -23,7 +23,7 @@ func TestGHMarkdown(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			got := ghMarkdownToSlack(data)
+			got := ghMarkdownToSlack(data, testUserMap, testGHRefs)
 			gotJSON, err := json.MarshalIndent(got, "", "  ")
 			if err != nil {
 				t.Fatal(err)
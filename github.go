@@ -1,30 +1,136 @@
 package spreche
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/bobg/go-generics/slices"
+	"github.com/bobg/go-generics/set"
+	"github.com/bobg/mid"
 	"github.com/google/go-github/v45/github"
 	"github.com/pkg/errors"
 	"github.com/slack-go/slack"
 )
 
+// OnGHWebhook validates an incoming GitHub webhook and queues it for processing.
+// The tenant is found from the GitHub server, app ID, and installation ID of the webhook,
+// and the payload must be signed with one of that tenant's webhook secrets
+// (see SecretStore and Service.FallbackSecrets).
+// If there is no such tenant (e.g. for a new installation),
+// s.GHSecret is used.
 func (s *Service) OnGHWebhook(w http.ResponseWriter, req *http.Request) error {
 	ctx := req.Context()
 
-	payload, err := github.ValidatePayload(req, []byte(s.GHSecret))
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading request body")
+	}
+
+	secrets := []string{s.GHSecret}
+	if instID, instAppID, senderURL := webhookInstallation(req.Header.Get("Content-Type"), body); instID != 0 {
+		apiURL, _, err := ghAPIURLs(senderURL)
+		if err != nil {
+			return err
+		}
+		appID := instAppID
+		if req.Header.Get("X-GitHub-Hook-Installation-Target-Type") == "integration" {
+			appID, _ = strconv.ParseInt(req.Header.Get("X-GitHub-Hook-Installation-Target-ID"), 10, 64)
+		}
+		if instAppID != 0 && appID != instAppID {
+			// Installation webhooks are handled with the payload's app ID
+			// (see Service.installationAppID),
+			// so it must be the one whose secrets verify them.
+			return mid.CodeErr{C: http.StatusBadRequest, Err: fmt.Errorf("webhook from app %d is for an installation of app %d", appID, instAppID)}
+		}
+		if appID == 0 {
+			appID = s.GHAppID
+		}
+		tenant, err := s.Tenants.ByGHInstallation(ctx, apiURL, appID, instID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return errors.Wrapf(err, "getting tenant for installation %d", instID)
+		}
+		if tenant != nil {
+			secrets, err = s.tenantSecrets(ctx, tenant.TenantID, SecretGHWebhook, s.GHSecret)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var payload []byte
+	for _, secret := range secrets {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		payload, err = github.ValidatePayload(req, []byte(secret))
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		return errors.Wrap(err, "validating webhook payload")
 	}
-	typ := github.WebHookType(req)
+	return s.enqueue(ctx, JobGitHub, github.WebHookType(req), github.DeliveryID(req), payload)
+}
+
+// webhookInstallation extracts the GitHub App installation ID from an unvalidated webhook body,
+// which may be JSON or form-encoded,
+// together with the installation's app ID
+// (present only in installation webhooks)
+// and the HTML URL of the event's sender
+// (which tells the GitHub server the webhook is from).
+// It returns a zero ID if there is none.
+func webhookInstallation(contentType string, body []byte) (instID, appID int64, senderURL string) {
+	if contentType == "application/x-www-form-urlencoded" {
+		vals, err := url.ParseQuery(string(body))
+		if err != nil {
+			return 0, 0, ""
+		}
+		body = []byte(vals.Get("payload"))
+	}
+	var p struct {
+		Installation struct {
+			ID    int64 `json:"id"`
+			AppID int64 `json:"app_id"`
+		} `json:"installation"`
+		Sender struct {
+			HTMLURL string `json:"html_url"`
+		} `json:"sender"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return 0, 0, ""
+	}
+	return p.Installation.ID, p.Installation.AppID, p.Sender.HTMLURL
+}
+
+func (s *Service) handleGHEvent(ctx context.Context, typ, deliveryID string, payload []byte) error {
 	ev, err := github.ParseWebHook(typ, payload)
 	if err != nil {
 		return errors.Wrap(err, "parsing webhook payload")
 	}
+
+	if ev, ok := ev.(*github.RepositoryEvent); ok {
+		return s.OnRepository(ctx, deliveryID, ev, payload)
+	}
+
+	repoEv, ok := ev.(interface{ GetRepo() *github.Repository })
+	if !ok || deliveryID == "" || repoEv.GetRepo().GetHTMLURL() == "" {
+		return s.dispatchGHEvent(ctx, ev)
+	}
+	return s.Tenants.WithTenant(ctx, 0, repoEv.GetRepo().GetHTMLURL(), "", func(ctx context.Context, tenant *Tenant) error {
+		return s.once(ctx, tenant.TenantID, deliveryID, func() error {
+			return s.dispatchGHEvent(ctx, ev)
+		})
+	})
+}
+
+func (s *Service) dispatchGHEvent(ctx context.Context, ev any) error {
 	switch ev := ev.(type) {
 	case *github.PullRequestEvent:
 		return s.OnPR(ctx, ev)
@@ -40,6 +146,21 @@ func (s *Service) OnGHWebhook(w http.ResponseWriter, req *http.Request) error {
 
 	case *github.PullRequestReviewThreadEvent:
 		return s.OnPRReviewThread(ctx, ev)
+
+	case *github.CheckRunEvent:
+		return s.OnCheckRun(ctx, ev)
+
+	case *github.CheckSuiteEvent:
+		return s.OnCheckSuite(ctx, ev)
+
+	case *github.StatusEvent:
+		return s.OnStatus(ctx, ev)
+
+	case *github.InstallationEvent:
+		return s.OnInstallation(ctx, ev)
+
+	case *github.InstallationRepositoriesEvent:
+		return s.OnInstallationRepositories(ctx, ev)
 	}
 
 	return fmt.Errorf("unknown webhook payload type %T", ev)
@@ -49,7 +170,10 @@ func (s *Service) OnPR(ctx context.Context, ev *github.PullRequestEvent) error {
 	return s.Tenants.WithTenant(ctx, 0, *ev.Repo.HTMLURL, "", func(ctx context.Context, tenant *Tenant) error {
 		debugf("In OnPR, tenantID is %d", tenant.TenantID)
 
-		return s.ensureChannel(ctx, tenant, ev.Repo, ev.PullRequest, func(channel *Channel) error {
+		// A channel created for any action other than "opened" is for a PR that already has history.
+		backfill := ev.GetAction() != "opened"
+
+		return s.ensureChannel(ctx, tenant, ev.Repo, ev.PullRequest, backfill, func(channel *Channel) error {
 			switch ev.GetAction() {
 			case "opened":
 				// everything is handled in ensureChannel
@@ -84,201 +208,398 @@ func (s *Service) OnPR(ctx context.Context, ev *github.PullRequestEvent) error {
 
 			case "synchronize":
 				return s.PRReviewRequestSynchronize(ctx, tenant, channel, ev)
+
+			case "converted_to_draft":
+				return s.PRConvertedToDraft(ctx, tenant, channel, ev)
+
+			case "ready_for_review":
+				return s.PRReadyForReview(ctx, tenant, channel, ev)
+
+			case "auto_merge_enabled", "auto_merge_disabled":
+				return s.PRAutoMerge(ctx, tenant, channel, ev)
+
+			case "locked", "unlocked", "milestoned", "demilestoned", "enqueued", "dequeued":
+				return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
 			}
 
-			return fmt.Errorf("unknown PR event action %s", ev.GetAction())
+			debugf("Ignoring PR event action %s", ev.GetAction())
+			return nil
 		})
 	})
 }
 
-func (s *Service) ensureChannel(ctx context.Context, tenant *Tenant, repo *github.Repository, pr *github.PullRequest, f func(*Channel) error) error {
+// ensureChannel calls f with the channel for the given PR,
+// creating it first if necessary.
+// If backfill is true,
+// a newly created channel is populated with the PR's existing discussion.
+// If the PR is a draft and the tenant defers drafts (see Tenant.DeferDrafts),
+// no channel is created and f is not called.
+//
+// The channel is recorded as soon as it exists,
+// its status card only once the channel is otherwise set up,
+// and the completion of its backfill at the end.
+// So a retry after a failure partway through finds the channel
+// and finishes setting it up.
+func (s *Service) ensureChannel(ctx context.Context, tenant *Tenant, repo *github.Repository, pr *github.PullRequest, backfill bool, f func(*Channel) error) error {
 	channel, err := s.Channels.ByRepoPR(ctx, tenant.TenantID, repo, *pr.Number)
 	if err != nil && !errors.Is(err, ErrNotFound) {
 		return err
 	}
 	sc := tenant.SlackClient()
+	chname := ChannelName(repo, *pr.Number)
 	if errors.Is(err, ErrNotFound) {
-		chname := ChannelName(repo, *pr.Number)
-		slackCh, err := sc.CreateConversationContext(ctx, chname, false)
+		if tenant.DeferDrafts && pr.GetDraft() {
+			debugf("Deferring channel creation for draft PR %d in %s", *pr.Number, *repo.HTMLURL)
+			return nil
+		}
+		channelID, err := createChannel(ctx, sc, chname)
 		if err != nil {
-			return errors.Wrapf(err, "creating channel %s", chname)
+			return err
 		}
-		debugf("Created channel %s, ID %s", chname, slackCh.ID)
-		err = setChannelTopic(ctx, sc, slackCh.ID, pr)
+		err = s.Channels.Add(ctx, tenant.TenantID, channelID, repo, *pr.Number, "")
+		if err != nil {
+			return errors.Wrap(err, "adding record to channel store")
+		}
+		if backfill {
+			err = s.Channels.SetBackfillPending(ctx, tenant.TenantID, channelID, true)
+			if err != nil {
+				return errors.Wrap(err, "recording pending backfill in channel store")
+			}
+		}
+		channel = &Channel{
+			ChannelID:       channelID,
+			Owner:           *repo.Owner.Login,
+			Repo:            *repo.Name,
+			PR:              *pr.Number,
+			SetupPending:    true,
+			BackfillPending: backfill,
+			State:           StateOpen,
+		}
+	}
+	if channel.SetupPending {
+		err = setChannelTopic(ctx, sc, channel.ChannelID, pr)
 		if err != nil {
 			return errors.Wrapf(err, "setting topic of channel %s", chname)
 		}
 		ghUsers := []*github.User{pr.User, pr.Assignee}
 		ghUsers = append(ghUsers, pr.Assignees...)
 		ghUsers = append(ghUsers, pr.RequestedReviewers...)
-		// xxx also pr.RequestedTeams?
 		slackUsers, err := s.GHToSlackUsers(ctx, tenant.TenantID, ghUsers)
 		if err != nil {
 			return errors.Wrap(err, "mapping GitHub to Slack users")
 		}
+		teamUsers, err := s.GHTeamsToSlackUsers(ctx, tenant, *repo.Owner.Login, pr.RequestedTeams)
+		if err != nil {
+			return errors.Wrap(err, "mapping GitHub teams to Slack users")
+		}
+		slackUsers = set.New(append(slackUsers, teamUsers...)...).Slice()
 		if len(slackUsers) > 0 {
-			_, err = sc.InviteUsersToConversationContext(ctx, slackCh.ID, slackUsers...)
-			if err != nil {
+			_, err = sc.InviteUsersToConversationContext(ctx, channel.ChannelID, slackUsers...)
+			if err != nil && !isSlackError(err, "already_in_channel", "cant_invite_self") {
 				return errors.Wrap(err, "inviting users to new channel")
 			}
 		}
-		ts, err := s.postToSlack(ctx, tenant, slackCh.ID, 0, prBodyPostOptions(pr)...)
+		ts, err := s.postStatusCard(ctx, tenant, channel.ChannelID, pr)
 		if err != nil {
-			return errors.Wrapf(err, "posting PR body in new channel %s", chname)
+			return errors.Wrapf(err, "posting status card in new channel %s", chname)
 		}
-		err = s.Channels.Add(ctx, tenant.TenantID, slackCh.ID, repo, *pr.Number, ts)
+		err = s.Channels.SetPRBodyTS(ctx, tenant.TenantID, channel.ChannelID, ts)
 		if err != nil {
-			return errors.Wrap(err, "adding record to channel store")
+			return errors.Wrap(err, "recording status card in channel store")
 		}
-		channel = &Channel{
-			ChannelID: slackCh.ID,
-			Owner:     *repo.Owner.Login,
-			Repo:      *repo.Name,
-			PR:        *pr.Number,
-			PRBodyTS:  ts,
+		channel.PRBodyTS, channel.SetupPending = ts, false
+	}
+	if channel.BackfillPending {
+		// Comments already imported by an earlier attempt are skipped.
+		err = s.backfill(ctx, tenant, channel)
+		if err != nil {
+			return errors.Wrapf(err, "importing existing discussion into channel %s", chname)
 		}
+		err = s.Channels.SetBackfillPending(ctx, tenant.TenantID, channel.ChannelID, false)
+		if err != nil {
+			return errors.Wrap(err, "recording finished backfill in channel store")
+		}
+		channel.BackfillPending = false
 	}
 	return f(channel)
 }
 
+// createChannel creates a Slack channel with the given name and returns its ID.
+// If the name is taken,
+// as when an earlier attempt created the channel but failed to record it,
+// that channel is reused.
+func createChannel(ctx context.Context, sc *slack.Client, chname string) (string, error) {
+	slackCh, err := sc.CreateConversationContext(ctx, chname, false)
+	if err == nil {
+		debugf("Created channel %s, ID %s", chname, slackCh.ID)
+		return slackCh.ID, nil
+	}
+	if !isSlackError(err, "name_taken") {
+		return "", errors.Wrapf(err, "creating channel %s", chname)
+	}
+
+	params := &slack.GetConversationsParameters{Limit: 1000, Types: []string{"public_channel"}}
+	for {
+		channels, cursor, err := sc.GetConversationsContext(ctx, params)
+		if err != nil {
+			return "", errors.Wrap(err, "listing channels")
+		}
+		for _, ch := range channels {
+			if ch.Name != chname {
+				continue
+			}
+			if ch.IsArchived {
+				if err = sc.UnArchiveConversationContext(ctx, ch.ID); err != nil {
+					return "", errors.Wrapf(err, "unarchiving existing channel %s", chname)
+				}
+			}
+			if !ch.IsMember {
+				if _, _, _, err = sc.JoinConversationContext(ctx, ch.ID); err != nil {
+					return "", errors.Wrapf(err, "joining existing channel %s", chname)
+				}
+			}
+			debugf("Reusing existing channel %s, ID %s", chname, ch.ID)
+			return ch.ID, nil
+		}
+		if cursor == "" {
+			return "", fmt.Errorf("channel name %s is taken, but no such channel was found", chname)
+		}
+		params.Cursor = cursor
+	}
+}
+
+// prChannel gets the channel for the given PR,
+// creating and backfilling it if necessary.
+// The PR may be nil,
+// in which case it is fetched from GitHub (by prnum) only if the channel must be created or its setup finished.
+// The result is nil if channel creation is deferred (see ensureChannel).
+func (s *Service) prChannel(ctx context.Context, tenant *Tenant, repo *github.Repository, pr *github.PullRequest, prnum int) (*Channel, error) {
+	if pr == nil {
+		channel, err := s.Channels.ByRepoPR(ctx, tenant.TenantID, repo, prnum)
+		if err == nil && !channel.SetupPending && !channel.BackfillPending {
+			return channel, nil
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		gh, err := tenant.GHClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "getting GitHub client")
+		}
+		pr, _, err = gh.PullRequests.Get(ctx, *repo.Owner.Login, *repo.Name, prnum)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting PR %d", prnum)
+		}
+	}
+
+	var result *Channel
+	err := s.ensureChannel(ctx, tenant, repo, pr, true, func(channel *Channel) error {
+		result = channel
+		return nil
+	})
+	return result, err
+}
+
+// OnPRReview posts a submitted review as a single message showing its verdict.
+// The review's line comments are posted in that message's thread
+// (see placeInReview).
+// Edited and dismissed reviews update the message.
 func (s *Service) OnPRReview(ctx context.Context, ev *github.PullRequestReviewEvent) error {
-	return s.someKindOfComment(ctx, ev, nil, nil)
+	return s.Tenants.WithTenant(ctx, 0, *ev.Repo.HTMLURL, "", func(ctx context.Context, tenant *Tenant) error {
+		debugf("In OnPRReview, tenant ID %d", tenant.TenantID)
+
+		channel, err := s.prChannel(ctx, tenant, ev.Repo, ev.PullRequest, *ev.PullRequest.Number)
+		if err != nil {
+			return errors.Wrapf(err, "getting channel for PR %d in %s", *ev.PullRequest.Number, *ev.Repo.HTMLURL)
+		}
+		if channel == nil {
+			return nil
+		}
+		if err = s.updateStatusCard(ctx, tenant, channel, ev.PullRequest); err != nil {
+			return err
+		}
+
+		comment := reviewToGHComment(ev.Review)
+		skip, err := s.skipGHComment(ctx, tenant, ev.Repo.GetFullName(), comment)
+		if err != nil || skip {
+			return err
+		}
+
+		switch ev.GetAction() {
+		case "submitted":
+			if !reviewGetsMessage(ev.Review) {
+				return nil
+			}
+			// If the channel was only just created,
+			// this review may already have been posted by the backfill.
+			_, err = s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, comment.commentID)
+			if err == nil {
+				debugf("Review %d already posted", comment.commentID)
+				return nil
+			}
+			if !errors.Is(err, ErrNotFound) {
+				return errors.Wrap(err, "looking up review record")
+			}
+			return s.postGHComment(ctx, tenant, channel, comment)
+
+		case "edited", "dismissed":
+			rec, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, comment.commentID)
+			if errors.Is(err, ErrNotFound) {
+				debugf("No message for review %d", comment.commentID)
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(err, "getting review record")
+			}
+			options, err := s.commentMsgOptions(ctx, tenant, channel, comment)
+			if err != nil {
+				return err
+			}
+			_, _, _, err = tenant.SlackClient().UpdateMessageContext(ctx, channel.ChannelID, rec.ThreadTimestamp, options...)
+			return errors.Wrap(err, "updating Slack review message")
+		}
+
+		return fmt.Errorf("unknown action %s", ev.GetAction())
+	})
 }
 
+// reviewGetsMessage tells whether a review is posted as a message of its own.
+// A review with no body and no verdict is just a container for line comments
+// (as when a single comment is added, or a thread is replied to),
+// so it is not.
+func reviewGetsMessage(review *github.PullRequestReview) bool {
+	if review.GetBody() != "" {
+		return true
+	}
+	switch strings.ToUpper(review.GetState()) {
+	case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+		return true
+	}
+	return false
+}
+
+// placeInReview decides whether a review comment goes in the thread of its review's message.
+// If the review has no message of its own,
+// it clears c.reviewID so the comment is posted at top level.
+// If the review should have a message but it has not been posted yet
+// (webhooks for a review's comments may be processed before the review's own),
+// it returns a notYetError so that the event is retried,
+// until maxReviewWait after the review was submitted.
+// After that it gives up on the review's message and clears c.reviewID.
+func (s *Service) placeInReview(ctx context.Context, tenant *Tenant, channel *Channel, c *ghComment) error {
+	if c.inReplyTo != 0 || c.reviewID == 0 {
+		return nil
+	}
+	_, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, c.reviewID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return errors.Wrap(err, "looking up review record")
+	}
+
+	gh, err := tenant.GHClient()
+	if err != nil {
+		return errors.Wrap(err, "getting GitHub client")
+	}
+	review, _, err := gh.PullRequests.GetReview(ctx, channel.Owner, channel.Repo, channel.PR, c.reviewID)
+	if err != nil {
+		return errors.Wrapf(err, "getting review %d", c.reviewID)
+	}
+	if reviewGetsMessage(review) {
+		skip, err := s.skipGHComment(ctx, tenant, channel.Owner+"/"+channel.Repo, reviewToGHComment(review))
+		if err != nil {
+			return err
+		}
+		if !skip {
+			if time.Since(review.GetSubmittedAt()) < maxReviewWait {
+				return notYetError{fmt.Errorf("message for review %d not yet posted", c.reviewID)}
+			}
+			log.Printf("Message for review %d not posted after %s, posting comment %d at top level", c.reviewID, maxReviewWait, c.commentID)
+		}
+	}
+	c.reviewID = 0
+	return nil
+}
+
+// maxReviewWait is how long a review comment waits for its review's message
+// before it is posted at top level instead.
+const maxReviewWait = 2 * time.Minute
+
 func (s *Service) OnIssueComment(ctx context.Context, ev *github.IssueCommentEvent) error {
 	if ev.Issue.PullRequestLinks == nil {
 		return nil
 	}
-	return s.someKindOfComment(ctx, nil, ev, nil)
+	return s.someKindOfComment(ctx, ev, nil)
 }
 
 func (s *Service) OnPRReviewComment(ctx context.Context, ev *github.PullRequestReviewCommentEvent) error {
-	return s.someKindOfComment(ctx, nil, nil, ev)
+	return s.someKindOfComment(ctx, nil, ev)
 }
 
-func (s *Service) someKindOfComment(ctx context.Context, review *github.PullRequestReviewEvent, issue *github.IssueCommentEvent, reviewComment *github.PullRequestReviewCommentEvent) error {
+func (s *Service) someKindOfComment(ctx context.Context, issue *github.IssueCommentEvent, reviewComment *github.PullRequestReviewCommentEvent) error {
 	var (
-		repo           *github.Repository
-		prnum          int
-		user           *github.User
-		action         string
-		commentID      int64
-		body, diffhunk *string
-		htmlURL, typ   string
-		isReply        bool
+		repo    *github.Repository
+		pr      *github.PullRequest
+		prnum   int
+		action  string
+		comment ghComment
+		changes *github.EditChange
 	)
 	switch {
-	case review != nil:
-		repo = review.Repo
-		prnum = *review.PullRequest.Number
-		user = review.Review.User
-		action = *review.Action
-		commentID = *review.Review.ID
-		body = review.Review.Body
-		htmlURL = *review.Review.HTMLURL
-		typ = "Review"
-
 	case issue != nil:
 		repo = issue.Repo
 		prnum = *issue.Issue.Number
-		user = issue.Comment.User
 		action = *issue.Action
-		commentID = *issue.Comment.ID
-		body = issue.Comment.Body
-		htmlURL = *issue.Comment.HTMLURL
-		typ = "Comment"
+		comment = issueCommentToGHComment(issue.Comment)
+		changes = issue.Changes
 
 	case reviewComment != nil:
 		repo = reviewComment.Repo
+		pr = reviewComment.PullRequest
 		prnum = *reviewComment.PullRequest.Number
-		user = reviewComment.Comment.User
 		action = *reviewComment.Action
-		commentID = *reviewComment.Comment.ID
-		body = reviewComment.Comment.Body
-		htmlURL = *reviewComment.Comment.HTMLURL
-		typ = "Review comment"
-		isReply = reviewComment.Comment.InReplyTo != nil && *reviewComment.Comment.InReplyTo != 0
-		diffhunk = reviewComment.Comment.DiffHunk
-	}
-	if body == nil || *body == "" {
-		return nil
+		comment = reviewCommentToGHComment(reviewComment.Comment)
+		changes = reviewComment.Changes
 	}
-	if user != nil && user.Type != nil && *user.Type == "Bot" {
+	if comment.body == "" {
 		return nil
 	}
 	return s.Tenants.WithTenant(ctx, 0, *repo.HTMLURL, "", func(ctx context.Context, tenant *Tenant) error {
 		debugf("In someKindOfComment, tenant ID %d", tenant.TenantID)
 
-		// xxx ensure channel exists
+		skip, err := s.skipGHComment(ctx, tenant, repo.GetFullName(), comment)
+		if err != nil || skip {
+			return err
+		}
 
-		channel, err := s.Channels.ByRepoPR(ctx, tenant.TenantID, repo, prnum)
+		channel, err := s.prChannel(ctx, tenant, repo, pr, prnum)
 		if err != nil {
 			return errors.Wrapf(err, "getting channel for PR %d in %s", prnum, *repo.HTMLURL)
 		}
+		if channel == nil {
+			return nil
+		}
 
-		var options []slack.MsgOption
-
-		if action != "deleted" {
-			contextBlockElements := []slack.MixedElement{slack.NewTextBlockObject(
-				"mrkdwn",
-				fmt.Sprintf("<%s|%s> by <%s|%s>", htmlURL, typ, *user.HTMLURL, *user.Login),
-				false,
-				false,
-			)}
-			if !isReply && diffhunk != nil && *diffhunk != "" {
-				// Trunc the hunk.
-				lines := strings.Split(*diffhunk, "\n")
-
-				// Trim off empty trailing lines
-				for len(lines) > 0 && lines[len(lines)-1] == "" {
-					lines = lines[:len(lines)-1]
-				}
-				if len(lines) > 3 {
-					lines = slices.RemoveTo(lines, -3, 0)
-				}
-				contextBlockElements = append(
-					contextBlockElements,
-					slack.NewTextBlockObject(
-						"mrkdwn",
-						"```\n"+strings.Join(lines, "\n")+"\n```", // xxx escaping?
-						false,
-						false,
-					),
-				)
+		if action == "created" {
+			// If the channel was only just created,
+			// this comment may already have been posted by the backfill.
+			_, err = s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, comment.commentID)
+			if err == nil {
+				debugf("Comment %d already posted", comment.commentID)
+				return nil
 			}
-
-			blocks := []slack.Block{slack.NewContextBlock("", contextBlockElements...)}
-			blocks = append(blocks, ghMarkdownToSlack([]byte(*body))...)
-			options = []slack.MsgOption{slack.MsgOptionBlocks(blocks...), slack.MsgOptionDisableLinkUnfurl()}
-
-			blocksJSON, _ := json.MarshalIndent(blocks, "", "  ")
-			fmt.Printf("xxx sending these blocks to Slack:\n%s\n", string(blocksJSON))
-
-			u, err := s.Users.ByGHLogin(ctx, tenant.TenantID, *user.Login)
-			switch {
-			case errors.Is(err, ErrNotFound):
-				// do nothing
-			case err != nil:
-				return errors.Wrapf(err, "looking up user %s", *user.Login)
-			default:
-				options = append(options, slack.MsgOptionUser(u.SlackID), slack.MsgOptionAsUser(true)) // xxx ?
+			if !errors.Is(err, ErrNotFound) {
+				return errors.Wrap(err, "looking up comment record")
 			}
 
-			if isReply {
-				comment, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, *reviewComment.Comment.InReplyTo)
-				if err != nil {
-					return errors.Wrap(err, "finding in-reply-to comment")
-				}
-				options = append(options, slack.MsgOptionTS(comment.ThreadTimestamp))
+			if err = s.placeInReview(ctx, tenant, channel, &comment); err != nil {
+				return err
 			}
+			return s.postGHComment(ctx, tenant, channel, comment)
 		}
 
-		if action == "created" {
-			_, err = s.postToSlack(ctx, tenant, channel.ChannelID, commentID, options...)
-			return errors.Wrap(err, "posting to Slack")
-		}
-
-		comment, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, commentID)
+		rec, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, comment.commentID)
 		if err != nil {
 			return errors.Wrap(err, "getting comment record")
 		}
@@ -287,11 +608,24 @@ func (s *Service) someKindOfComment(ctx context.Context, review *github.PullRequ
 
 		switch action {
 		case "edited":
-			_, _, _, err = sc.UpdateMessageContext(ctx, channel.ChannelID, comment.ThreadTimestamp, options...)
-			return errors.Wrap(err, "updating Slack comment")
+			if err = s.placeInReview(ctx, tenant, channel, &comment); err != nil {
+				return err
+			}
+			options, err := s.commentMsgOptions(ctx, tenant, channel, comment)
+			if err != nil {
+				return err
+			}
+			_, _, _, err = sc.UpdateMessageContext(ctx, channel.ChannelID, rec.ThreadTimestamp, options...)
+			if err != nil {
+				return errors.Wrap(err, "updating Slack comment")
+			}
+			if changes != nil && changes.Body != nil {
+				s.tryProxyGHImages(ctx, tenant, channel.ChannelID, rec.ThreadTimestamp, comment.body, changes.Body.GetFrom())
+			}
+			return nil
 
 		case "deleted":
-			_, _, err = sc.DeleteMessageContext(ctx, channel.ChannelID, comment.ThreadTimestamp)
+			_, _, err = sc.DeleteMessageContext(ctx, channel.ChannelID, rec.ThreadTimestamp)
 			return errors.Wrap(err, "deleting Slack comment")
 
 		default:
@@ -300,31 +634,289 @@ func (s *Service) someKindOfComment(ctx context.Context, review *github.PullRequ
 	})
 }
 
+// ghComment is the information needed to post a GitHub issue comment, review, or review comment to Slack.
+type ghComment struct {
+	commentID int64
+	user      *github.User
+	body      string
+	htmlURL   string
+	typ       string
+	hunk      *hunkRef
+	inReplyTo int64
+
+	// reviewID is the review containing a review comment.
+	reviewID int64
+
+	// state is the verdict of a review,
+	// in the uppercase form used by the GitHub API
+	// (APPROVED, CHANGES_REQUESTED, COMMENTED, DISMISSED).
+	state string
+}
+
+func reviewToGHComment(review *github.PullRequestReview) ghComment {
+	return ghComment{
+		commentID: review.GetID(),
+		user:      review.User,
+		body:      review.GetBody(),
+		htmlURL:   review.GetHTMLURL(),
+		typ:       "Review",
+		state:     strings.ToUpper(review.GetState()),
+	}
+}
+
+func issueCommentToGHComment(comment *github.IssueComment) ghComment {
+	return ghComment{
+		commentID: comment.GetID(),
+		user:      comment.User,
+		body:      comment.GetBody(),
+		htmlURL:   comment.GetHTMLURL(),
+		typ:       "Comment",
+	}
+}
+
+func reviewCommentToGHComment(comment *github.PullRequestComment) ghComment {
+	return ghComment{
+		commentID: comment.GetID(),
+		user:      comment.User,
+		body:      comment.GetBody(),
+		htmlURL:   comment.GetHTMLURL(),
+		typ:       "Review comment",
+		hunk:      reviewCommentHunkRef(comment),
+		inReplyTo: comment.GetInReplyTo(),
+		reviewID:  comment.GetPullRequestReviewID(),
+	}
+}
+
+func (c ghComment) isBot() bool {
+	return c.user != nil && c.user.Type != nil && *c.user.Type == "Bot"
+}
+
+// kind gives the CommentKind constant for the comment.
+func (c ghComment) kind() string {
+	switch c.typ {
+	case "Review":
+		return CommentKindReview
+	case "Review comment":
+		return CommentKindReviewComment
+	default:
+		return CommentKindIssueComment
+	}
+}
+
+// eventType gives the type of GitHub webhook event that reports this comment.
+func (c ghComment) eventType() string {
+	switch c.typ {
+	case "Review":
+		return "pull_request_review"
+	case "Review comment":
+		return "pull_request_review_comment"
+	default:
+		return "issue_comment"
+	}
+}
+
+// commentMsgOptions produces the options for posting (or updating) a GitHub comment as a Slack message.
+// If the comment is a reply,
+// the options place it in the Slack thread of the comment it replies to.
+// Otherwise if it is part of a review,
+// they place it in the thread of the review's message.
+func (s *Service) commentMsgOptions(ctx context.Context, tenant *Tenant, channel *Channel, c ghComment) ([]slack.MsgOption, error) {
+	header := fmt.Sprintf("<%s|%s> by <%s|%s>", c.htmlURL, c.typ, *c.user.HTMLURL, *c.user.Login)
+	if c.state != "" {
+		header += fmt.Sprintf(": %s %s", reviewStateEmoji(c.state), reviewStateText(c.state))
+	}
+	contextBlockElements := []slack.MixedElement{slack.NewTextBlockObject("mrkdwn", header, false, false)}
+	var hunkBlocks []slack.Block
+	if c.inReplyTo == 0 && c.hunk != nil {
+		contextBlockElements = append(contextBlockElements, slack.NewTextBlockObject("mrkdwn", c.hunk.refMrkdwn(c.htmlURL), false, false))
+		if code := c.hunk.codeMrkdwn(tenant.DiffContextLines); code != "" {
+			hunkBlocks = append(hunkBlocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", code, false, false), nil, nil))
+		}
+	}
+
+	users, err := s.ghUserMap(ctx, tenant.TenantID, c.body)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := newGHRefs(tenant.GHAPIURL, channel.Owner, channel.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []slack.Block{slack.NewContextBlock("", contextBlockElements...)}
+	blocks = append(blocks, hunkBlocks...)
+	blocks = append(blocks, commentBodyBlocks(c, users, refs)...)
+	if tenant.ProxyImages {
+		blocks = withoutImageBlocks(blocks, refs.htmlBase)
+	}
+	options := []slack.MsgOption{slack.MsgOptionBlocks(blocks...), slack.MsgOptionDisableLinkUnfurl()}
+
+	blocksJSON, _ := json.MarshalIndent(blocks, "", "  ")
+	fmt.Printf("xxx sending these blocks to Slack:\n%s\n", string(blocksJSON))
+
+	u, err := s.Users.ByGHLogin(ctx, tenant.TenantID, *c.user.Login)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// do nothing
+	case err != nil:
+		return nil, errors.Wrapf(err, "looking up user %s", *c.user.Login)
+	default:
+		options = append(options, slack.MsgOptionUser(u.SlackID), slack.MsgOptionAsUser(true)) // xxx ?
+	}
+
+	switch {
+	case c.inReplyTo != 0:
+		comment, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, c.inReplyTo)
+		if err != nil {
+			return nil, errors.Wrap(err, "finding in-reply-to comment")
+		}
+		// The comment replied to may itself be in a review's thread.
+		root, err := threadRoot(ctx, tenant.SlackClient(), channel.ChannelID, comment.ThreadTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, slack.MsgOptionTS(root))
+
+	case c.reviewID != 0:
+		review, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, c.reviewID)
+		if err != nil {
+			return nil, errors.Wrap(err, "finding review message")
+		}
+		options = append(options, slack.MsgOptionTS(review.ThreadTimestamp))
+	}
+
+	return options, nil
+}
+
+// postGHComment posts a GitHub comment as a Slack message and records it,
+// then proxies its images if needed.
+func (s *Service) postGHComment(ctx context.Context, tenant *Tenant, channel *Channel, c ghComment) error {
+	options, err := s.commentMsgOptions(ctx, tenant, channel, c)
+	if err != nil {
+		return err
+	}
+	ts, err := s.postToSlack(ctx, tenant, channel.ChannelID, c.commentID, c.kind(), options...)
+	if err != nil {
+		return errors.Wrap(err, "posting to Slack")
+	}
+	s.tryProxyGHImages(ctx, tenant, channel.ChannelID, ts, c.body, "")
+	return nil
+}
+
+// OnPRReviewThread posts a notice that a review thread was resolved or unresolved
+// in the Slack thread of its first comment.
+// If the tenant's MarkResolved option is set,
+// it also marks (or unmarks) the first comment's message.
 func (s *Service) OnPRReviewThread(ctx context.Context, ev *github.PullRequestReviewThreadEvent) error {
 	return s.Tenants.WithTenant(ctx, 0, *ev.Repo.HTMLURL, "", func(ctx context.Context, tenant *Tenant) error {
 		debugf("In OnPRReviewThread, tenant ID %d", tenant.TenantID)
 
-		// xxx ensure channel exists
-
-		channel, err := s.Channels.ByRepoPR(ctx, tenant.TenantID, ev.Repo, *ev.PullRequest.Number)
+		channel, err := s.prChannel(ctx, tenant, ev.Repo, ev.PullRequest, *ev.PullRequest.Number)
 		if err != nil {
 			return errors.Wrapf(err, "getting channel for PR %d in %s", *ev.PullRequest.Number, *ev.Repo.HTMLURL)
 		}
+		if channel == nil {
+			return nil
+		}
 
+		if ev.Thread == nil || len(ev.Thread.Comments) == 0 {
+			return nil
+		}
+		first := ev.Thread.Comments[0]
+		rec, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, first.GetID())
+		if errors.Is(err, ErrNotFound) {
+			debugf("No message for comment %d, first in thread %d", first.GetID(), ev.Thread.GetID())
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "getting comment record")
+		}
+
+		sc := tenant.SlackClient()
+
+		root, err := threadRoot(ctx, sc, channel.ChannelID, rec.ThreadTimestamp)
+		if err != nil {
+			return err
+		}
+		msg := fmt.Sprintf("_This thread was marked %s by %s_", ev.GetAction(), ev.GetSender().GetLogin())
 		options := []slack.MsgOption{
-			// xxx slack.MsgOptionsTs(...)?
-			// xxx slack.MsgOptionUser(...)?
-			// xxx slack.MsgOptionAsUser(...)?
-			slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject(
-				"mrkdwn",
-				fmt.Sprintf("_This thread was marked %s by %s_", *ev.Action, *ev.Sender.Login),
-				false,
-				false,
-			))),
-		}
-		_, err = s.postToSlack(ctx, tenant, channel.ChannelID, *ev.Thread.ID, options...)
-		return errors.Wrap(err, "posting to Slack")
+			slack.MsgOptionTS(root),
+			slack.MsgOptionText(msg, false),
+			slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", msg, false, false))),
+		}
+		_, err = s.postToSlack(ctx, tenant, channel.ChannelID, 0, "", options...)
+		if err != nil {
+			return errors.Wrap(err, "posting to Slack")
+		}
+
+		if !tenant.MarkResolved {
+			return nil
+		}
+
+		var (
+			ref     = slack.NewRefToMessage(channel.ChannelID, rec.ThreadTimestamp)
+			comment = reviewCommentToGHComment(first)
+		)
+		comment.reviewID = 0 // not needed for updating the message
+
+		switch ev.GetAction() {
+		case "resolved":
+			err = sc.AddReactionContext(ctx, resolvedReaction, ref)
+			if err != nil && !isSlackError(err, "already_reacted") {
+				return errors.Wrap(err, "adding reaction")
+			}
+			_, _, _, err = sc.UpdateMessageContext(ctx, channel.ChannelID, rec.ThreadTimestamp, resolvedMsgOptions(comment, ev.GetSender())...)
+			return errors.Wrap(err, "collapsing resolved comment")
+
+		case "unresolved":
+			err = sc.RemoveReactionContext(ctx, resolvedReaction, ref)
+			if err != nil && !isSlackError(err, "no_reaction") {
+				return errors.Wrap(err, "removing reaction")
+			}
+			options, err := s.commentMsgOptions(ctx, tenant, channel, comment)
+			if err != nil {
+				return err
+			}
+			_, _, _, err = sc.UpdateMessageContext(ctx, channel.ChannelID, rec.ThreadTimestamp, options...)
+			return errors.Wrap(err, "restoring unresolved comment")
+		}
+
+		return nil
+	})
+}
+
+// resolvedReaction is the reaction added to the first comment of a resolved review thread
+// when the tenant's MarkResolved option is set.
+const resolvedReaction = "white_check_mark"
+
+// resolvedMsgOptions produces the collapsed form of the message for the first comment of a resolved review thread.
+func resolvedMsgOptions(c ghComment, resolver *github.User) []slack.MsgOption {
+	text := fmt.Sprintf(
+		"~<%s|%s> by <%s|%s>~ _resolved by %s_",
+		c.htmlURL, c.typ, c.user.GetHTMLURL(), c.user.GetLogin(), resolver.GetLogin(),
+	)
+	return []slack.MsgOption{
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", text, false, false))),
+		slack.MsgOptionDisableLinkUnfurl(),
+	}
+}
+
+// threadRoot gives the timestamp of the root of the Slack thread containing the message with timestamp ts.
+// That is ts itself if the message is not a thread reply.
+func threadRoot(ctx context.Context, sc *slack.Client, channelID, ts string) (string, error) {
+	msgs, _, _, err := sc.GetConversationRepliesContext(ctx, &slack.GetConversationRepliesParameters{
+		ChannelID: channelID,
+		Timestamp: ts,
+		Limit:     1,
 	})
+	if err != nil {
+		return "", errors.Wrapf(err, "getting thread of message %s", ts)
+	}
+	if len(msgs) > 0 && msgs[0].ThreadTimestamp != "" {
+		return msgs[0].ThreadTimestamp, nil
+	}
+	return ts, nil
 }
 
 func (s *Service) PRReviewRequested(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
@@ -336,22 +928,41 @@ func (s *Service) PRReviewRequestRemoved(ctx context.Context, tenant *Tenant, ch
 }
 
 func (s *Service) reviewRequest(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent, requested bool) error {
-	var (
-		requestedFromTeam bool
-		requestedFrom     string
-	)
-	if ev.RequestedReviewer != nil {
-		requestedFrom = *ev.RequestedReviewer.Login
-	} else if ev.RequestedTeam != nil {
-		requestedFromTeam = true
-		requestedFrom = *ev.RequestedTeam.Name
-	}
-	if requestedFrom == "" {
+	// Invites for a draft PR wait for PRReadyForReview.
+	deferInvites := tenant.DeferDrafts && ev.PullRequest.GetDraft()
+
+	var requestedFrom string
+	switch {
+	case ev.RequestedReviewer != nil:
+		var err error
+		if requested && deferInvites {
+			requestedFrom = ghUserLink(ev.RequestedReviewer)
+		} else if requested {
+			requestedFrom, err = s.inviteToChannel(ctx, tenant, channel, ev.RequestedReviewer)
+		} else {
+			// Nobody is removed from the channel when a request is withdrawn:
+			// they may have joined on their own or still be involved in the PR.
+			requestedFrom, err = s.slackUserRef(ctx, tenant, ev.RequestedReviewer)
+		}
+		if err != nil {
+			return err
+		}
+
+	case ev.RequestedTeam != nil:
+		if requested && !deferInvites {
+			var err error
+			requestedFrom, err = s.inviteTeamToChannel(ctx, tenant, channel, ev.RequestedTeam)
+			if err != nil {
+				return err
+			}
+		} else {
+			// Don't ping the whole group about a withdrawn request.
+			requestedFrom = ghTeamLink(ev.RequestedTeam)
+		}
+
+	default:
 		return nil
 	}
-	if requestedFromTeam {
-		requestedFrom = "team " + requestedFrom
-	}
 
 	var msg string
 	if requested {
@@ -359,15 +970,10 @@ func (s *Service) reviewRequest(ctx context.Context, tenant *Tenant, channel *Ch
 	} else {
 		msg = fmt.Sprintf("_Review request from %s removed by %s_", requestedFrom, *ev.Sender.Login)
 	}
-
-	options := []slack.MsgOption{
-		// xxx slack.MsgOptionsTs(...)?
-		// xxx slack.MsgOptionUser(...)?
-		// xxx slack.MsgOptionAsUser(...)?
-		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", msg, false, false))),
+	if err := s.postNotice(ctx, tenant, channel, msg); err != nil {
+		return err
 	}
-	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, options...)
-	return errors.Wrap(err, "posting to Slack")
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
 }
 
 func (s *Service) PRReviewRequestSynchronize(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
@@ -382,88 +988,152 @@ func (s *Service) PRReviewRequestSynchronize(ctx context.Context, tenant *Tenant
 			false,
 		))),
 	}
-	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, options...)
-	return errors.Wrap(err, "posting to Slack")
+	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, "", options...)
+	if err != nil {
+		return errors.Wrap(err, "posting to Slack")
+	}
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
 }
 
-func (s *Service) PRReviewRequestLabeled(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
-	options := []slack.MsgOption{
-		// xxx slack.MsgOptionsTs(...)?
-		// xxx slack.MsgOptionUser(...)?
-		// xxx slack.MsgOptionAsUser(...)?
-		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject(
-			"mrkdwn",
-			fmt.Sprintf("_Label `%s` added by %s_", *ev.Label.Name, *ev.Sender.Login),
-			false,
-			false,
-		))),
+func (s *Service) PRConvertedToDraft(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
+	err := setChannelTopic(ctx, tenant.SlackClient(), channel.ChannelID, ev.PullRequest)
+	if err != nil {
+		return errors.Wrap(err, "setting channel topic")
 	}
-	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, options...)
-	return errors.Wrap(err, "posting to Slack")
+	err = s.postNotice(ctx, tenant, channel, fmt.Sprintf("_This PR was converted to a draft by %s_", *ev.Sender.Login))
+	if err != nil {
+		return err
+	}
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
 }
 
-func (s *Service) PRReviewRequestUnlabeled(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
-	options := []slack.MsgOption{
-		// xxx slack.MsgOptionsTs(...)?
-		// xxx slack.MsgOptionUser(...)?
-		// xxx slack.MsgOptionAsUser(...)?
-		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject(
-			"mrkdwn",
-			fmt.Sprintf("_Label `%s` removed by %s_", *ev.Label.Name, *ev.Sender.Login),
-			false,
-			false,
-		))),
+func (s *Service) PRReadyForReview(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
+	err := setChannelTopic(ctx, tenant.SlackClient(), channel.ChannelID, ev.PullRequest)
+	if err != nil {
+		return errors.Wrap(err, "setting channel topic")
 	}
-	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, options...)
-	return errors.Wrap(err, "posting to Slack")
+
+	// Reviewer invites are deferred while the PR is a draft
+	// only if the tenant defers drafts.
+	// Otherwise the reviewers were invited (and pinged) when they were requested.
+	var reviewers []string
+	if tenant.DeferDrafts {
+		for _, u := range ev.PullRequest.RequestedReviewers {
+			mention, err := s.inviteToChannel(ctx, tenant, channel, u)
+			if err != nil {
+				return err
+			}
+			reviewers = append(reviewers, mention)
+		}
+		for _, team := range ev.PullRequest.RequestedTeams {
+			mention, err := s.inviteTeamToChannel(ctx, tenant, channel, team)
+			if err != nil {
+				return err
+			}
+			reviewers = append(reviewers, mention)
+		}
+	}
+
+	msg := fmt.Sprintf("_This PR was marked ready for review by %s_", *ev.Sender.Login)
+	if len(reviewers) > 0 {
+		msg += fmt.Sprintf("\nReviewers: %s", strings.Join(reviewers, ", "))
+	}
+	if err = s.postNotice(ctx, tenant, channel, msg); err != nil {
+		return err
+	}
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
+}
+
+func (s *Service) PRAutoMerge(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
+	var msg string
+	if ev.GetAction() == "auto_merge_enabled" {
+		msg = fmt.Sprintf("_Auto-merge enabled by %s_", *ev.Sender.Login)
+		if method := ev.PullRequest.GetAutoMerge().GetMergeMethod(); method != "" {
+			msg = fmt.Sprintf("_Auto-merge (%s) enabled by %s_", method, *ev.Sender.Login)
+		}
+	} else {
+		msg = fmt.Sprintf("_Auto-merge disabled by %s_", *ev.Sender.Login)
+	}
+	if err := s.postNotice(ctx, tenant, channel, msg); err != nil {
+		return err
+	}
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
+}
+
+func (s *Service) PRReviewRequestLabeled(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
+}
+
+func (s *Service) PRReviewRequestUnlabeled(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
 }
 
 func (s *Service) PRAssigned(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
-	options := []slack.MsgOption{
-		// xxx slack.MsgOptionsTs(...)?
-		// xxx slack.MsgOptionUser(...)?
-		// xxx slack.MsgOptionAsUser(...)?
-		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject(
-			"mrkdwn",
-			fmt.Sprintf("_PR assigned to %s by %s_", *ev.Assignee.Login, *ev.Sender.Login),
-			false,
-			false,
-		))),
+	if ev.Assignee != nil {
+		assignee, err := s.inviteToChannel(ctx, tenant, channel, ev.Assignee)
+		if err != nil {
+			return err
+		}
+		err = s.postNotice(ctx, tenant, channel, fmt.Sprintf("_Assigned to %s by %s_", assignee, *ev.Sender.Login))
+		if err != nil {
+			return err
+		}
 	}
-	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, options...)
-	return errors.Wrap(err, "posting to Slack")
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
 }
 
 func (s *Service) PRUnassigned(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
-	options := []slack.MsgOption{
-		// xxx slack.MsgOptionsTs(...)?
-		// xxx slack.MsgOptionUser(...)?
-		// xxx slack.MsgOptionAsUser(...)?
-		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject(
-			"mrkdwn",
-			fmt.Sprintf("_PR assignment to %s removed by %s_", *ev.Assignee.Login, *ev.Sender.Login),
-			false,
-			false,
-		))),
+	if ev.Assignee != nil {
+		assignee, err := s.slackUserRef(ctx, tenant, ev.Assignee)
+		if err != nil {
+			return err
+		}
+		err = s.postNotice(ctx, tenant, channel, fmt.Sprintf("_%s unassigned by %s_", assignee, *ev.Sender.Login))
+		if err != nil {
+			return err
+		}
 	}
-	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, options...)
-	return errors.Wrap(err, "posting to Slack")
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
 }
 
 func (s *Service) PRClosed(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
+	var (
+		state    = StateClosed
+		verb     = "closed"
+		closedAt = ev.PullRequest.GetClosedAt()
+	)
+	if ev.PullRequest.GetMerged() {
+		state, verb = StateMerged, "merged"
+	}
+	if closedAt.IsZero() {
+		closedAt = time.Now()
+	}
+
 	options := []slack.MsgOption{
 		// xxx slack.MsgOptionsTs(...)?
 		// xxx slack.MsgOptionUser(...)?
 		// xxx slack.MsgOptionAsUser(...)?
 		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject(
 			"mrkdwn",
-			fmt.Sprintf("_This PR was closed by %s_", *ev.Sender.Login),
+			fmt.Sprintf("_This PR was %s by %s_", verb, *ev.Sender.Login),
 			false,
 			false,
 		))),
 	}
-	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, options...)
-	return errors.Wrap(err, "posting to Slack")
+	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, "", options...)
+	if err != nil {
+		return errors.Wrap(err, "posting to Slack")
+	}
+
+	err = s.Channels.SetState(ctx, tenant.TenantID, channel.ChannelID, state, closedAt)
+	if err != nil {
+		return errors.Wrap(err, "recording PR state")
+	}
+	err = s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
+	if err != nil {
+		return err
+	}
+	return s.scheduleArchive(ctx, tenant, channel, closedAt)
 }
 
 func (s *Service) PREdited(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
@@ -479,35 +1149,38 @@ func (s *Service) PREdited(ctx context.Context, tenant *Tenant, channel *Channel
 			return errors.Wrap(err, "setting channel topic")
 		}
 	}
-	if ev.Changes.Body != nil {
-		_, _, _, err := sc.UpdateMessageContext(ctx, channel.ChannelID, channel.PRBodyTS, prBodyPostOptions(ev.PullRequest)...)
-		if err != nil {
-			return errors.Wrap(err, "updating PR body message")
-		}
-	}
-	// xxx also ev.Changes.Base and ev.Changes.Repo ?
-
-	return nil
-}
+	// xxx also ev.Changes.Repo ?
 
-func prBodyPostOptions(pr *github.PullRequest) []slack.MsgOption {
-	body := "[no content]"
-	if pr.Body != nil {
-		body = *pr.Body
+	if err := s.updateStatusCard(ctx, tenant, channel, ev.PullRequest); err != nil {
+		return err
 	}
-	return []slack.MsgOption{
-		slack.MsgOptionDisableLinkUnfurl(),
-		slack.MsgOptionBlocks(ghMarkdownToSlack([]byte(body))...),
+	if ev.Changes.Body != nil && channel.PRBodyTS != "" {
+		s.tryProxyGHImages(ctx, tenant, channel.ChannelID, channel.PRBodyTS, ev.PullRequest.GetBody(), ev.Changes.Body.GetFrom())
 	}
+	return nil
 }
 
 func setChannelTopic(ctx context.Context, sc *slack.Client, channelID string, pr *github.PullRequest) error {
 	topic := fmt.Sprintf("Discussion of %s: %s by %s", *pr.HTMLURL, *pr.Title, *pr.User.Login)
+	if pr.GetDraft() {
+		topic = "[draft] " + topic
+	}
 	_, err := sc.SetTopicOfConversationContext(ctx, channelID, topic)
 	return err
 }
 
 func (s *Service) PRReopened(ctx context.Context, tenant *Tenant, channel *Channel, ev *github.PullRequestEvent) error {
+	err := s.Channels.SetState(ctx, tenant.TenantID, channel.ChannelID, StateOpen, time.Time{})
+	if err != nil {
+		return errors.Wrap(err, "recording PR state")
+	}
+	if !channel.ArchivedAt.IsZero() {
+		err = s.unarchiveChannel(ctx, tenant, channel)
+		if err != nil {
+			return err
+		}
+	}
+
 	options := []slack.MsgOption{
 		// xxx slack.MsgOptionsTs(...)?
 		// xxx slack.MsgOptionUser(...)?
@@ -519,11 +1192,28 @@ func (s *Service) PRReopened(ctx context.Context, tenant *Tenant, channel *Chann
 			false,
 		))),
 	}
-	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, options...)
+	_, err = s.postToSlack(ctx, tenant, channel.ChannelID, 0, "", options...)
+	if err != nil {
+		return errors.Wrap(err, "posting to Slack")
+	}
+	return s.updateStatusCard(ctx, tenant, channel, ev.PullRequest)
+}
+
+// postNotice posts an mrkdwn-formatted notice about the PR as a context block in its channel.
+func (s *Service) postNotice(ctx context.Context, tenant *Tenant, channel *Channel, msg string) error {
+	options := []slack.MsgOption{
+		slack.MsgOptionText(msg, false),
+		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", msg, false, false))),
+	}
+	_, err := s.postToSlack(ctx, tenant, channel.ChannelID, 0, "", options...)
 	return errors.Wrap(err, "posting to Slack")
 }
 
-func (s *Service) postToSlack(ctx context.Context, tenant *Tenant, channelID string, commentID int64, options ...slack.MsgOption) (string, error) {
+// postToSlack posts a message to a Slack channel, returning its timestamp.
+// If commentID is non-zero,
+// it also records the message as the counterpart of the GitHub comment with that ID and kind
+// (one of the CommentKind constants).
+func (s *Service) postToSlack(ctx context.Context, tenant *Tenant, channelID string, commentID int64, kind string, options ...slack.MsgOption) (string, error) {
 	sc := tenant.SlackClient()
 	_, timestamp, err := sc.PostMessageContext(ctx, channelID, options...)
 	if err != nil {
@@ -532,6 +1222,12 @@ func (s *Service) postToSlack(ctx context.Context, tenant *Tenant, channelID str
 	if commentID == 0 {
 		return timestamp, nil
 	}
-	err = s.Comments.Add(ctx, tenant.TenantID, channelID, timestamp, commentID)
+	err = s.Comments.Add(ctx, tenant.TenantID, channelID, timestamp, commentID, kind)
 	return timestamp, errors.Wrap(err, "adding comment record")
 }
+
+// isGHNotFound tells whether err is a GitHub API 404 response.
+func isGHNotFound(err error) bool {
+	var e *github.ErrorResponse
+	return errors.As(err, &e) && e.Response != nil && e.Response.StatusCode == http.StatusNotFound
+}
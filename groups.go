@@ -0,0 +1,137 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+)
+
+// GroupStore is a persistent store associating GitHub teams with Slack user groups.
+type GroupStore interface {
+	// ByGHTeam finds the Slack user group for a GitHub team,
+	// identified by its org and slug.
+	// If there is none it returns ErrNotFound.
+	ByGHTeam(ctx context.Context, tenantID int64, org, slug string) (*Group, error)
+
+	// Add adds a mapping to the store,
+	// replacing any existing one for the same GitHub team.
+	Add(context.Context, int64, *Group) error
+
+	// Remove removes the mapping for a GitHub team.
+	Remove(ctx context.Context, tenantID int64, org, slug string) error
+
+	// Foreach calls f on each of a tenant's mappings.
+	Foreach(ctx context.Context, tenantID int64, f func(*Group) error) error
+}
+
+type Group struct {
+	GHOrg        string `json:"gh_org"`
+	GHTeam       string `json:"gh_team"` // the team slug
+	SlackGroupID string `json:"slack_group_id"`
+}
+
+// GHTeamsToSlackUsers finds the members of the Slack user groups
+// that correspond to the given GitHub teams in the given org.
+// Teams with no corresponding user group are skipped.
+func (s *Service) GHTeamsToSlackUsers(ctx context.Context, tenant *Tenant, org string, teams []*github.Team) ([]string, error) {
+	var result []string
+
+	sc := tenant.SlackClient()
+
+	for _, team := range teams {
+		if team.GetSlug() == "" {
+			continue
+		}
+		g, err := s.Groups.ByGHTeam(ctx, tenant.TenantID, org, team.GetSlug())
+		if errors.Is(err, ErrNotFound) {
+			debugf("No Slack user group found for GitHub team %s/%s", org, team.GetSlug())
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "looking up team %s/%s", org, team.GetSlug())
+		}
+		members, err := sc.GetUserGroupMembersContext(ctx, g.SlackGroupID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting members of Slack user group %s", g.SlackGroupID)
+		}
+		debugf("Found %d Slack users in group %s for GitHub team %s/%s", len(members), g.SlackGroupID, org, team.GetSlug())
+		result = append(result, members...)
+	}
+
+	return result, nil
+}
+
+// inviteTeamToChannel invites the members of the Slack user group for a GitHub team (if there is one) into a PR channel.
+// It returns a mention of the group suitable for Slack mrkdwn.
+func (s *Service) inviteTeamToChannel(ctx context.Context, tenant *Tenant, channel *Channel, team *github.Team) (string, error) {
+	g, err := s.Groups.ByGHTeam(ctx, tenant.TenantID, channel.Owner, team.GetSlug())
+	if errors.Is(err, ErrNotFound) {
+		return ghTeamLink(team), nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "looking up team %s/%s", channel.Owner, team.GetSlug())
+	}
+
+	sc := tenant.SlackClient()
+
+	members, err := sc.GetUserGroupMembersContext(ctx, g.SlackGroupID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting members of Slack user group %s", g.SlackGroupID)
+	}
+
+	// Invite one at a time,
+	// so that members already in the channel don't cause the others to be skipped.
+	for _, member := range members {
+		_, err = sc.InviteUsersToConversationContext(ctx, channel.ChannelID, member)
+		if err != nil && !isSlackError(err, "already_in_channel", "cant_invite_self") {
+			return "", errors.Wrapf(err, "inviting %s to channel %s", member, channel.ChannelID)
+		}
+	}
+
+	return fmt.Sprintf("<!subteam^%s>", g.SlackGroupID), nil
+}
+
+// SyncGroup sets the members of a Slack user group
+// to the Slack counterparts of the members of its GitHub team.
+// GitHub team members with no Slack counterpart are skipped.
+func (s *Service) SyncGroup(ctx context.Context, tenant *Tenant, g *Group) error {
+	gh, err := tenant.GHClient()
+	if err != nil {
+		return errors.Wrap(err, "getting GitHub client")
+	}
+
+	var ghUsers []*github.User
+
+	opts := &github.TeamListTeamMembersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		users, resp, err := gh.Teams.ListTeamMembersBySlug(ctx, g.GHOrg, g.GHTeam, opts)
+		if err != nil {
+			return errors.Wrapf(err, "listing members of team %s/%s", g.GHOrg, g.GHTeam)
+		}
+		ghUsers = append(ghUsers, users...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	slackUsers, err := s.GHToSlackUsers(ctx, tenant.TenantID, ghUsers)
+	if err != nil {
+		return errors.Wrap(err, "mapping GitHub to Slack users")
+	}
+	if len(slackUsers) == 0 {
+		// Slack does not allow a user group to be emptied this way.
+		return fmt.Errorf("no members of team %s/%s have Slack counterparts", g.GHOrg, g.GHTeam)
+	}
+
+	sc := tenant.SlackClient()
+	_, err = sc.UpdateUserGroupMembersContext(ctx, g.SlackGroupID, strings.Join(slackUsers, ","))
+	return errors.Wrapf(err, "updating members of Slack user group %s", g.SlackGroupID)
+}
+
+func ghTeamLink(team *github.Team) string {
+	return fmt.Sprintf("team <%s|%s>", team.GetHTMLURL(), team.GetName())
+}
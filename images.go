@@ -0,0 +1,356 @@
+package spreche
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/bobg/htree"
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/golang-commonmark/markdown"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+	"golang.org/x/net/html"
+)
+
+// Slack renders images in messages by fetching them itself,
+// which fails for attachments in private repos and on GitHub Enterprise servers.
+// For a tenant with ProxyImages set,
+// the GitHub-hosted images in a comment are instead fetched with the tenant's GitHub credentials
+// and uploaded as files in the Slack thread of the comment's message.
+
+const (
+	// maxProxyImageSize is the size of the largest image that is proxied.
+	maxProxyImageSize = 5 * 1024 * 1024
+
+	// maxImageCacheSize is the total size of the images kept in an imageCache.
+	maxImageCacheSize = 64 * 1024 * 1024
+)
+
+// ghImage is an image in GitHub markdown.
+type ghImage struct {
+	url, alt string
+}
+
+// ghImages finds the images in GitHub markdown,
+// whether in markdown syntax or in HTML img tags.
+// Each URL appears once.
+func ghImages(body string) []ghImage {
+	var (
+		result []ghImage
+		seen   = make(map[string]bool)
+	)
+	add := func(img ghImage) {
+		if img.url != "" && !seen[img.url] {
+			seen[img.url] = true
+			result = append(result, img)
+		}
+	}
+
+	var walk func([]markdown.Token)
+	walk = func(tokens []markdown.Token) {
+		for _, tok := range tokens {
+			switch tok := tok.(type) {
+			case *markdown.Inline:
+				walk(tok.Children)
+			case *markdown.Image:
+				add(ghImage{url: tok.Src, alt: ghTokensToPlainText(tok.Tokens)})
+			case *markdown.HTMLBlock:
+				htmlImages(tok.Content, add)
+			case *markdown.HTMLInline:
+				htmlImages(tok.Content, add)
+			}
+		}
+	}
+	walk(markdown.New(markdown.HTML(true)).Parse([]byte(body)))
+
+	return result
+}
+
+// htmlImages calls f on each img element in an HTML fragment.
+func htmlImages(fragment string, f func(ghImage)) {
+	node, err := html.Parse(strings.NewReader(fragment))
+	if err != nil {
+		return
+	}
+	htree.FindAllEls(node, func(n *html.Node) bool { return n.Data == "img" }, func(n *html.Node) error {
+		f(ghImage{url: htree.ElAttr(n, "src"), alt: htree.ElAttr(n, "alt")})
+		return nil
+	})
+}
+
+// needsImageProxy tells whether an image URL is hosted by the GitHub server with the given HTML base URL
+// (see ghHTMLBase).
+func needsImageProxy(htmlBase, imageURL string) bool {
+	u, err := url.Parse(imageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	base, err := url.Parse(htmlBase)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, base.Host) || strings.HasSuffix(strings.ToLower(u.Hostname()), ".githubusercontent.com")
+}
+
+// withoutImageBlocks replaces the image blocks that need proxying
+// with the same placeholder text used for inline images.
+func withoutImageBlocks(blocks []slack.Block, htmlBase string) []slack.Block {
+	for i, block := range blocks {
+		img, ok := block.(*slack.ImageBlock)
+		if !ok || !needsImageProxy(htmlBase, img.ImageURL) {
+			continue
+		}
+		var title string
+		if img.Title != nil {
+			title = img.Title.Text
+		}
+		blocks[i] = slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("[image %s]", title), false, false), nil, nil)
+	}
+	return blocks
+}
+
+// tryProxyGHImages is proxyGHImages for callers that have already posted or updated the message.
+// Proxying images is best-effort:
+// an error is logged rather than returned,
+// so that it cannot fail the job that posted the message
+// (whose retry would then find the message already posted and proxy nothing).
+func (s *Service) tryProxyGHImages(ctx context.Context, tenant *Tenant, channelID, ts, body, oldBody string) {
+	if err := s.proxyGHImages(ctx, tenant, channelID, ts, body, oldBody); err != nil {
+		log.Printf("Error proxying images for message %s in channel %s: %s", ts, channelID, err)
+	}
+}
+
+// proxyGHImages uploads the GitHub-hosted images in a comment body
+// to the Slack thread of the message with timestamp ts,
+// if the tenant's ProxyImages option is set.
+// For an edited comment, oldBody is its previous body,
+// whose images have already been proxied and are skipped.
+// An image that cannot be fetched is skipped.
+func (s *Service) proxyGHImages(ctx context.Context, tenant *Tenant, channelID, ts, body, oldBody string) error {
+	if !tenant.ProxyImages {
+		return nil
+	}
+
+	htmlBase, err := ghHTMLBase(tenant.GHAPIURL)
+	if err != nil {
+		return err
+	}
+	proxied := make(map[string]bool)
+	for _, img := range ghImages(oldBody) {
+		proxied[img.url] = true
+	}
+	var imgs []ghImage
+	for _, img := range ghImages(body) {
+		if !proxied[img.url] && needsImageProxy(htmlBase, img.url) {
+			imgs = append(imgs, img)
+		}
+	}
+	if len(imgs) == 0 {
+		return nil
+	}
+
+	client, err := tenant.ghFetchClient(htmlBase)
+	if err != nil {
+		return err
+	}
+
+	sc := tenant.SlackClient()
+
+	threadTS, err := threadRoot(ctx, sc, channelID, ts)
+	if err != nil {
+		return err
+	}
+
+	for _, img := range imgs {
+		cached, err := s.fetchImage(ctx, client, tenant.TenantID, img.url)
+		if err != nil {
+			debugf("Not proxying image %s: %s", img.url, err)
+			continue
+		}
+
+		filename := path.Base(cached.url.Path)
+		if path.Ext(filename) == "" {
+			filename += imageExt(cached.contentType)
+		}
+		title := img.alt
+		if title == "" {
+			title = filename
+		}
+
+		_, err = sc.UploadFileContext(ctx, slack.FileUploadParameters{
+			Reader:          bytes.NewReader(cached.data),
+			Filename:        filename,
+			Title:           title,
+			Channels:        []string{channelID},
+			ThreadTimestamp: threadTS,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "uploading image %s to Slack", img.url)
+		}
+	}
+
+	return nil
+}
+
+// fetchImage gets an image with the given client,
+// or from the cache if it was gotten recently.
+func (s *Service) fetchImage(ctx context.Context, client *http.Client, tenantID int64, imageURL string) (*cachedImage, error) {
+	// Images are cached per tenant,
+	// since each tenant's credentials reach different private images.
+	key := fmt.Sprintf("%d %s", tenantID, imageURL)
+	if img, ok := s.images.get(key); ok {
+		return img, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating request for %s", imageURL)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching %s", imageURL)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d fetching %s", resp.StatusCode, imageURL)
+	}
+	if resp.ContentLength > maxProxyImageSize {
+		return nil, fmt.Errorf("image is %d bytes, more than the limit of %d", resp.ContentLength, maxProxyImageSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProxyImageSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", imageURL)
+	}
+	if len(data) > maxProxyImageSize {
+		return nil, fmt.Errorf("image is more than the limit of %d bytes", maxProxyImageSize)
+	}
+
+	// Going by the content rather than the Content-Type header
+	// guards against a login page or the like.
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("content type %s is not an image", contentType)
+	}
+
+	img := &cachedImage{
+		key:         key,
+		url:         resp.Request.URL,
+		data:        data,
+		contentType: contentType,
+	}
+	s.images.put(img)
+	return img, nil
+}
+
+// imageExt gives a filename extension for an image content type.
+func imageExt(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/bmp":
+		return ".bmp"
+	default:
+		return ""
+	}
+}
+
+// ghFetchClient produces an HTTP client
+// that sends requests for the GitHub server with the given HTML base URL
+// with the tenant's installation credentials,
+// and requests for other hosts
+// (like the storage service that GitHub redirects attachment downloads to)
+// without them.
+func (t *Tenant) ghFetchClient(htmlBase string) (*http.Client, error) {
+	itr, err := ghinstallation.New(http.DefaultTransport, t.GHAppID, t.GHInstallationID, t.GHPrivKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating transport for GitHub client")
+	}
+	itr.BaseURL = t.GHAPIURL
+	u, err := url.Parse(htmlBase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing URL %s", htmlBase)
+	}
+	return &http.Client{Transport: hostTransport{host: u.Host, auth: itr}}, nil
+}
+
+// hostTransport sends requests for one host through auth,
+// and others through http.DefaultTransport.
+type hostTransport struct {
+	host string
+	auth http.RoundTripper
+}
+
+func (t hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.EqualFold(req.URL.Host, t.host) {
+		return t.auth.RoundTrip(req)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// imageCache is a size-limited cache of fetched images,
+// discarding the least recently used first.
+// The zero value is an empty cache ready to use.
+type imageCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element // each Value is a *cachedImage
+	lru     list.List                // most recently used at the front
+}
+
+type cachedImage struct {
+	key         string
+	url         *url.URL // after any redirects
+	data        []byte
+	contentType string
+}
+
+func (c *imageCache) get(key string) (*cachedImage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cachedImage), true
+}
+
+func (c *imageCache) put(img *cachedImage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	if elem, ok := c.entries[img.key]; ok {
+		c.size -= len(elem.Value.(*cachedImage).data)
+		c.lru.Remove(elem)
+	}
+	c.entries[img.key] = c.lru.PushFront(img)
+	c.size += len(img.data)
+
+	for c.size > maxImageCacheSize {
+		elem := c.lru.Back()
+		old := elem.Value.(*cachedImage)
+		c.lru.Remove(elem)
+		delete(c.entries, old.key)
+		c.size -= len(old.data)
+	}
+}
@@ -0,0 +1,136 @@
+package spreche
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestGHImages(t *testing.T) {
+	body := `Before:
+
+![old screenshot](https://github.com/user-attachments/assets/1111)
+
+After: <img width="300" alt="new screenshot" src="https://github.com/user-attachments/assets/2222">
+
+<p><img src="https://private-user-images.githubusercontent.com/3333.png"></p>
+
+Again: ![same](https://github.com/user-attachments/assets/1111)`
+
+	got := ghImages(body)
+	want := []ghImage{
+		{url: "https://github.com/user-attachments/assets/1111", alt: "old screenshot"},
+		{url: "https://github.com/user-attachments/assets/2222", alt: "new screenshot"},
+		{url: "https://private-user-images.githubusercontent.com/3333.png"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNeedsImageProxy(t *testing.T) {
+	cases := []struct {
+		htmlBase, url string
+		want          bool
+	}{
+		{"https://github.com", "https://github.com/user-attachments/assets/1111", true},
+		{"https://github.com", "https://user-images.githubusercontent.com/1/2.png", true},
+		{"https://github.com", "https://example.com/x.png", false},
+		{"https://github.com", "data:image/png;base64,AAAA", false},
+		{"https://ghe.example.com", "https://ghe.example.com/storage/user/1/files/2", true},
+		{"https://ghe.example.com", "https://github.com/user-attachments/assets/1111", false},
+	}
+	for i, tc := range cases {
+		if got := needsImageProxy(tc.htmlBase, tc.url); got != tc.want {
+			t.Errorf("case %d: got %v, want %v", i+1, got, tc.want)
+		}
+	}
+}
+
+func TestWithoutImageBlocks(t *testing.T) {
+	blocks := []slack.Block{
+		slack.NewImageBlock("https://github.com/user-attachments/assets/1111", "", "", slack.NewTextBlockObject(slack.PlainTextType, "screenshot", false, false)),
+		slack.NewImageBlock("https://example.com/x.png", "", "", nil),
+	}
+	blocks = withoutImageBlocks(blocks, "https://github.com")
+
+	section, ok := blocks[0].(*slack.SectionBlock)
+	if !ok {
+		t.Fatalf("got %T, want *slack.SectionBlock", blocks[0])
+	}
+	if section.Text.Text != "[image screenshot]" {
+		t.Errorf("got %q, want %q", section.Text.Text, "[image screenshot]")
+	}
+	if _, ok := blocks[1].(*slack.ImageBlock); !ok {
+		t.Errorf("got %T, want *slack.ImageBlock", blocks[1])
+	}
+}
+
+func TestProxyGHImages(t *testing.T) {
+	const channelID = "C0PRCHAN"
+
+	s, api := newFakeService(t, channelID)
+
+	ctx := context.Background()
+	tenant := s.Tenants.(fakeTenantStore).tenant
+
+	body := "![screenshot](" + api.URL + "/user-attachments/assets/1111) ![elsewhere](https://example.com/x.png)"
+
+	if err := s.proxyGHImages(ctx, tenant, channelID, "1.000000", body, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("POST /slack/files.upload"); n != 0 {
+		t.Errorf("got %d uploads with ProxyImages off, want 0", n)
+	}
+
+	tenant.ProxyImages = true
+	for i := 0; i < 2; i++ {
+		if err := s.proxyGHImages(ctx, tenant, channelID, "1.000000", body, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := api.count("GET /user-attachments/"); n != 1 {
+		t.Errorf("got %d fetches, want 1", n)
+	}
+	if n := api.count("POST /slack/files.upload"); n != 2 {
+		t.Errorf("got %d uploads, want 2", n)
+	}
+
+	// An edit proxies only the images it adds.
+	edited := body + " ![another](" + api.URL + "/user-attachments/assets/2222)"
+	if err := s.proxyGHImages(ctx, tenant, channelID, "1.000000", edited, body); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("GET /user-attachments/assets/2222"); n != 1 {
+		t.Errorf("got %d fetches of the added image, want 1", n)
+	}
+	if n := api.count("POST /slack/files.upload"); n != 3 {
+		t.Errorf("got %d uploads after edit, want 3", n)
+	}
+}
+
+func TestImageCache(t *testing.T) {
+	var c imageCache
+
+	const third = maxImageCacheSize / 3
+	for _, key := range []string{"a", "b", "c"} {
+		c.put(&cachedImage{key: key, data: make([]byte, third)})
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("a not cached")
+	}
+
+	// This should evict b, the least recently used.
+	c.put(&cachedImage{key: "d", data: make([]byte, third)})
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if _, got := c.get(key); got != want {
+			t.Errorf("%s: got cached %v, want %v", key, got, want)
+		}
+	}
+	if c.size > maxImageCacheSize {
+		t.Errorf("cache size %d exceeds limit %d", c.size, maxImageCacheSize)
+	}
+}
@@ -0,0 +1,246 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bobg/go-generics/set"
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+)
+
+// Installing the GitHub App creates a pending tenant (see TenantPending)
+// holding the installation's repos.
+// An operator completes it with "admin tenant complete",
+// which attaches a Slack team and its signing secret.
+
+// OnInstallation handles an installation webhook.
+func (s *Service) OnInstallation(ctx context.Context, ev *github.InstallationEvent) error {
+	switch ev.GetAction() {
+	case "created", "new_permissions_accepted":
+		return s.provisionTenant(ctx, ev.Installation, ev.Repositories, nil)
+
+	case "deleted", "suspend":
+		return s.setInstallationState(ctx, ev.Installation, TenantDisabled)
+
+	case "unsuspend":
+		return s.setInstallationState(ctx, ev.Installation, "")
+	}
+
+	debugf("Ignoring installation event action %s", ev.GetAction())
+	return nil
+}
+
+// OnInstallationRepositories handles an installation_repositories webhook,
+// sent when repos are added to or removed from an installation.
+func (s *Service) OnInstallationRepositories(ctx context.Context, ev *github.InstallationRepositoriesEvent) error {
+	inst := ev.Installation
+	if ev.RepositorySelection != nil {
+		// This can change independently of the installation in the payload.
+		inst.RepositorySelection = ev.RepositorySelection
+	}
+	return s.provisionTenant(ctx, inst, ev.RepositoriesAdded, ev.RepositoriesRemoved)
+}
+
+// provisionTenant creates or updates the tenant for a GitHub App installation,
+// recording the GitHub URLs it covers.
+// If the installation has access to all of its account's repos,
+// that is the account's URL.
+// Otherwise it is the URLs of the added repos,
+// less those of the removed ones.
+func (s *Service) provisionTenant(ctx context.Context, inst *github.Installation, added, removed []*github.Repository) error {
+	accountURL := inst.GetAccount().GetHTMLURL()
+	if accountURL == "" {
+		return fmt.Errorf("no account URL in installation %d", inst.GetID())
+	}
+
+	var addURLs, removeURLs []string
+	if inst.GetRepositorySelection() == "all" {
+		addURLs = []string{accountURL}
+	} else {
+		removeURLs = []string{accountURL}
+		for _, r := range added {
+			u, err := installationRepoURL(accountURL, r)
+			if err != nil {
+				return err
+			}
+			addURLs = append(addURLs, u)
+		}
+	}
+	for _, r := range removed {
+		u, err := installationRepoURL(accountURL, r)
+		if err != nil {
+			return err
+		}
+		removeURLs = append(removeURLs, u)
+	}
+
+	apiURL, uploadURL, err := ghAPIURLs(accountURL)
+	if err != nil {
+		return err
+	}
+	appID := s.installationAppID(inst)
+
+	tenant, err := s.Tenants.ByGHInstallation(ctx, apiURL, appID, inst.GetID())
+	if errors.Is(err, ErrNotFound) {
+		if s.GHAppID == 0 || len(s.GHPrivKey) == 0 {
+			return fmt.Errorf("cannot create tenant for installation %d: no GitHub App ID and private key configured", inst.GetID())
+		}
+		if appID != s.GHAppID {
+			return fmt.Errorf("cannot create tenant for installation %d of app %d: only app %d is configured", inst.GetID(), appID, s.GHAppID)
+		}
+		tenant = &Tenant{
+			GHInstallationID: inst.GetID(),
+			GHPrivKey:        s.GHPrivKey,
+			GHAPIURL:         apiURL,
+			GHUploadURL:      uploadURL,
+			GHAppID:          s.GHAppID,
+			State:            TenantPending,
+			GHURLs:           addURLs,
+		}
+		if err = s.Tenants.Add(ctx, tenant); err != nil {
+			return errors.Wrapf(err, "adding tenant for installation %d", inst.GetID())
+		}
+		if s.GHSecret != "" {
+			// The app's webhooks are signed with this secret
+			// (which is what verified this one).
+			err = s.Secrets.Add(ctx, &Secret{TenantID: tenant.TenantID, Kind: SecretGHWebhook, Secret: s.GHSecret})
+			if err != nil {
+				return errors.Wrapf(err, "adding webhook secret for tenant %d", tenant.TenantID)
+			}
+		}
+		debugf("Created pending tenant %d for installation %d", tenant.TenantID, inst.GetID())
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting tenant for installation %d", inst.GetID())
+	}
+
+	if tenant.State == TenantDisabled {
+		tenant.State = tenantReenabledState(tenant)
+		if err = s.Tenants.Update(ctx, tenant); err != nil {
+			return errors.Wrapf(err, "re-enabling tenant %d", tenant.TenantID)
+		}
+	}
+
+	existing := set.New(tenant.GHURLs...)
+	for _, u := range removeURLs {
+		if !existing.Has(u) {
+			continue
+		}
+		if err = s.Tenants.RemoveGHURL(ctx, tenant.TenantID, u); err != nil {
+			return errors.Wrapf(err, "removing GitHub URL %s from tenant %d", u, tenant.TenantID)
+		}
+		existing.Del(u)
+	}
+	for _, u := range addURLs {
+		if existing.Has(u) {
+			continue
+		}
+		if err = s.Tenants.AddGHURL(ctx, tenant.TenantID, u); err != nil {
+			return errors.Wrapf(err, "adding GitHub URL %s to tenant %d", u, tenant.TenantID)
+		}
+		existing.Add(u)
+	}
+	return nil
+}
+
+// setInstallationState sets the state of the tenant for a GitHub App installation.
+// An empty state means the tenant's state before it was disabled.
+// It is not an error if there is no such tenant.
+func (s *Service) setInstallationState(ctx context.Context, inst *github.Installation, state string) error {
+	apiURL, _, err := ghAPIURLs(inst.GetAccount().GetHTMLURL())
+	if err != nil {
+		return err
+	}
+	tenant, err := s.Tenants.ByGHInstallation(ctx, apiURL, s.installationAppID(inst), inst.GetID())
+	if errors.Is(err, ErrNotFound) {
+		debugf("No tenant for installation %d", inst.GetID())
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting tenant for installation %d", inst.GetID())
+	}
+	if state == "" {
+		if tenant.State != TenantDisabled {
+			return nil
+		}
+		state = tenantReenabledState(tenant)
+	}
+	if tenant.State == state {
+		return nil
+	}
+	debugf("Changing state of tenant %d from %s to %s", tenant.TenantID, tenant.State, state)
+	tenant.State = state
+	err = s.Tenants.Update(ctx, tenant)
+	return errors.Wrapf(err, "updating tenant %d", tenant.TenantID)
+}
+
+// tenantReenabledState is the state for a disabled tenant whose installation is restored:
+// active if it has been attached to a Slack team,
+// otherwise pending.
+func tenantReenabledState(tenant *Tenant) string {
+	if tenant.SlackToken != "" && len(tenant.TeamIDs) > 0 {
+		return TenantActive
+	}
+	return TenantPending
+}
+
+// installationRepoURL computes the HTML URL of a repo in an installation webhook,
+// whose repos lack that field.
+func installationRepoURL(accountURL string, repo *github.Repository) (string, error) {
+	if u := repo.GetHTMLURL(); u != "" {
+		return u, nil
+	}
+	u, err := url.Parse(accountURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing URL %s", accountURL)
+	}
+	u.Path = "/" + repo.GetFullName()
+	return u.String(), nil
+}
+
+// installationAppID gives the ID of the GitHub App of an installation,
+// defaulting to the Service's own.
+func (s *Service) installationAppID(inst *github.Installation) int64 {
+	if id := inst.GetAppID(); id != 0 {
+		return id
+	}
+	return s.GHAppID
+}
+
+// canonicalGHAPIURL puts a GitHub API URL in the form produced by ghAPIURLs,
+// which is how it is stored in Tenant.GHAPIURL.
+// Like github.NewEnterpriseClient,
+// it adds a missing trailing slash and, for a GitHub Enterprise server, a missing /api/v3/.
+func canonicalGHAPIURL(apiURL string) (string, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing URL %s", apiURL)
+	}
+	if strings.EqualFold(u.Host, "api.github.com") {
+		return "https://api.github.com/", nil
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	if !strings.HasSuffix(u.Path, "/api/v3/") {
+		u.Path += "api/v3/"
+	}
+	return u.String(), nil
+}
+
+// ghAPIURLs gives the API and upload URLs for the GitHub server hosting the given HTML URL.
+func ghAPIURLs(htmlURL string) (apiURL, uploadURL string, err error) {
+	u, err := url.Parse(htmlURL)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "parsing URL %s", htmlURL)
+	}
+	if strings.EqualFold(u.Host, "github.com") {
+		return "https://api.github.com/", "https://uploads.github.com/", nil
+	}
+	base := u.Scheme + "://" + u.Host
+	return base + "/api/v3/", base + "/api/uploads/", nil
+}
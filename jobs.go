@@ -0,0 +1,243 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JobStore is a persistent queue of incoming events awaiting processing.
+type JobStore interface {
+	// Add adds a new pending job to the store,
+	// runnable at the job's RunAt time,
+	// or immediately if that is zero.
+	// On a successful return, the JobID field of the object is populated with the new ID.
+	Add(context.Context, *Job) error
+
+	// Claim finds the pending job with the earliest run time not after now,
+	// marks it running,
+	// increments its attempt count,
+	// and returns it.
+	// If there is no such job it returns ErrNotFound.
+	Claim(ctx context.Context, now time.Time) (*Job, error)
+
+	// Done removes a successfully processed job from the store.
+	Done(ctx context.Context, jobID int64) error
+
+	// Retry returns a job to the pending state, to be run again no earlier than runAt.
+	Retry(ctx context.Context, jobID int64, runAt time.Time, errmsg string) error
+
+	// Kill moves a job to the dead-letter state.
+	Kill(ctx context.Context, jobID int64, errmsg string) error
+
+	// Requeue returns a dead job to the pending state with its attempt count reset.
+	Requeue(ctx context.Context, jobID int64) error
+
+	// Reset returns all running jobs to the pending state.
+	// It is for recovering jobs that were interrupted by a shutdown or crash.
+	Reset(context.Context) error
+
+	// Foreach calls f on each job in the given state, in run-time order.
+	Foreach(ctx context.Context, state string, f func(*Job) error) error
+}
+
+// Job is an incoming event awaiting processing.
+type Job struct {
+	JobID int64 `json:"job_id"`
+
+	// Kind is JobGitHub, JobSlack, JobSlackAction, or JobArchive.
+	Kind string `json:"kind"`
+
+	// EventType is the GitHub webhook event type (from the X-GitHub-Event header).
+	// It is empty for Slack jobs.
+	EventType string `json:"event_type,omitempty"`
+
+	// DeliveryID is the GitHub delivery GUID (from the X-GitHub-Delivery header),
+	// the Slack event_id,
+	// or the Slack trigger_id of an interaction.
+	// It is used to recognize redeliveries.
+	DeliveryID string `json:"delivery_id,omitempty"`
+
+	// Payload is the verified request body.
+	// For JobSlackAction it is the interaction payload in JSON form.
+	// For JobArchive it is an archiveRequest in JSON form.
+	Payload []byte `json:"-"`
+
+	State     string    `json:"state"`
+	Attempts  int       `json:"attempts"`
+	RunAt     time.Time `json:"run_at"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Job kinds.
+const (
+	JobGitHub      = "github"
+	JobSlack       = "slack"
+	JobSlackAction = "slack_action"
+	JobArchive     = "archive"
+)
+
+// Job states.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDead    = "dead"
+)
+
+const (
+	maxJobAttempts  = 8
+	jobBackoffBase  = 5 * time.Second
+	jobBackoffMax   = time.Hour
+	jobPollInterval = time.Second
+)
+
+func (s *Service) enqueue(ctx context.Context, kind, eventType, deliveryID string, payload []byte) error {
+	job := &Job{
+		Kind:       kind,
+		EventType:  eventType,
+		DeliveryID: deliveryID,
+		Payload:    payload,
+	}
+	if err := s.Jobs.Add(ctx, job); err != nil {
+		return errors.Wrap(err, "adding job")
+	}
+	debugf("Enqueued %s job %d (%s)", kind, job.JobID, eventType)
+	return nil
+}
+
+// RunWorkers processes queued jobs with n concurrent workers until the context is canceled.
+func (s *Service) RunWorkers(ctx context.Context, n int) error {
+	if err := s.Jobs.Reset(ctx); err != nil {
+		return errors.Wrap(err, "resetting interrupted jobs")
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.expireDeliveries(ctx)
+	}()
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (s *Service) worker(ctx context.Context) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain all runnable jobs before waiting for the next tick.
+		for {
+			job, err := s.Jobs.Claim(ctx, time.Now())
+			if errors.Is(err, ErrNotFound) {
+				break
+			}
+			if err != nil {
+				log.Printf("Error claiming job: %s", err)
+				break
+			}
+			s.runJob(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runJob(ctx context.Context, job *Job) {
+	panicked, err := s.processJobSafely(ctx, job)
+	if err == nil {
+		if err = s.Jobs.Done(ctx, job.JobID); err != nil {
+			log.Printf("Error removing finished job %d: %s", job.JobID, err)
+		}
+		return
+	}
+
+	// A job that panicked will presumably panic again,
+	// so it is not retried.
+	if panicked || job.Attempts >= maxJobAttempts {
+		log.Printf("Job %d failed on attempt %d, giving up: %s", job.JobID, job.Attempts, err)
+		if err2 := s.Jobs.Kill(ctx, job.JobID, err.Error()); err2 != nil {
+			log.Printf("Error moving job %d to dead-letter state: %s", job.JobID, err2)
+		}
+		return
+	}
+
+	delay := jobBackoff(job.Attempts)
+	if errors.As(err, new(notYetError)) {
+		debugf("Job %d not ready on attempt %d, retrying in %s: %s", job.JobID, job.Attempts, delay, err)
+	} else {
+		log.Printf("Job %d failed on attempt %d, retrying in %s: %s", job.JobID, job.Attempts, delay, err)
+	}
+	if err2 := s.Jobs.Retry(ctx, job.JobID, time.Now().Add(delay), err.Error()); err2 != nil {
+		log.Printf("Error rescheduling job %d: %s", job.JobID, err2)
+	}
+}
+
+// notYetError is an error from a job that is waiting for another job to run first.
+// It is retried like any other error,
+// but it is not logged as a failure.
+type notYetError struct {
+	error
+}
+
+// processJobSafely calls processJob,
+// turning a panic into an error
+// so that one bad job cannot take down the process.
+func (s *Service) processJobSafely(ctx context.Context, job *Job) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in job %d: %v\n%s", job.JobID, r, debug.Stack())
+			panicked, err = true, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return false, s.processJob(ctx, job)
+}
+
+func (s *Service) processJob(ctx context.Context, job *Job) error {
+	switch job.Kind {
+	case JobGitHub:
+		return s.handleGHEvent(ctx, job.EventType, job.DeliveryID, job.Payload)
+
+	case JobSlack:
+		return s.handleSlackEvent(ctx, job.DeliveryID, job.Payload)
+
+	case JobSlackAction:
+		return s.handleSlackAction(ctx, job.DeliveryID, job.Payload)
+
+	case JobArchive:
+		return s.archiveChannel(ctx, job.Payload)
+	}
+
+	return fmt.Errorf("unknown job kind %s", job.Kind)
+}
+
+// jobBackoff tells how long to wait before retrying a job that has failed the given number of times.
+func jobBackoff(attempts int) time.Duration {
+	d := jobBackoffBase
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= jobBackoffMax {
+			return jobBackoffMax
+		}
+	}
+	return d
+}
@@ -0,0 +1,56 @@
+package spreche
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// recordingJobStore is a JobStore that records what becomes of a job.
+// Only the methods used by runJob do anything.
+type recordingJobStore struct {
+	JobStore
+	outcome string
+}
+
+func (r *recordingJobStore) Done(context.Context, int64) error {
+	r.outcome = "done"
+	return nil
+}
+
+func (r *recordingJobStore) Retry(context.Context, int64, time.Time, string) error {
+	r.outcome = "retry"
+	return nil
+}
+
+func (r *recordingJobStore) Kill(context.Context, int64, string) error {
+	r.outcome = "kill"
+	return nil
+}
+
+func TestRunJobPanic(t *testing.T) {
+	store := new(recordingJobStore)
+
+	// With no TenantStore, handling this event panics.
+	s := &Service{Jobs: store}
+	job := &Job{
+		JobID:    1,
+		Kind:     JobSlack,
+		Attempts: 1,
+		Payload:  []byte(`{"type": "event_callback", "team_id": "T0TEAM", "event": {"type": "message"}}`),
+	}
+	s.runJob(context.Background(), job)
+
+	if store.outcome != "kill" {
+		t.Errorf("got outcome %q, want kill", store.outcome)
+	}
+}
+
+func TestRunJobError(t *testing.T) {
+	store := new(recordingJobStore)
+	s := &Service{Jobs: store}
+	s.runJob(context.Background(), &Job{JobID: 1, Kind: "bogus", Attempts: 1})
+	if store.outcome != "retry" {
+		t.Errorf("got outcome %q, want retry", store.outcome)
+	}
+}
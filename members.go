@@ -0,0 +1,65 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// inviteToChannel invites the Slack counterpart of a GitHub user (if there is one) into a PR channel.
+// It returns a mention of the user suitable for Slack mrkdwn.
+func (s *Service) inviteToChannel(ctx context.Context, tenant *Tenant, channel *Channel, ghUser *github.User) (string, error) {
+	slackUsers, err := s.GHToSlackUsers(ctx, tenant.TenantID, []*github.User{ghUser})
+	if err != nil {
+		return "", errors.Wrap(err, "mapping GitHub to Slack users")
+	}
+	if len(slackUsers) == 0 {
+		return ghUserLink(ghUser), nil
+	}
+	sc := tenant.SlackClient()
+	_, err = sc.InviteUsersToConversationContext(ctx, channel.ChannelID, slackUsers...)
+	if err != nil && !isSlackError(err, "already_in_channel", "cant_invite_self") {
+		return "", errors.Wrapf(err, "inviting %s to channel %s", ghUser.GetLogin(), channel.ChannelID)
+	}
+	return slackUserMention(slackUsers[0]), nil
+}
+
+// slackUserRef produces a reference to a GitHub user suitable for Slack mrkdwn:
+// a mention of the user's Slack counterpart if there is one,
+// and otherwise a link to the user's GitHub profile.
+// Unlike inviteToChannel it does not change the channel's membership.
+func (s *Service) slackUserRef(ctx context.Context, tenant *Tenant, ghUser *github.User) (string, error) {
+	slackUsers, err := s.GHToSlackUsers(ctx, tenant.TenantID, []*github.User{ghUser})
+	if err != nil {
+		return "", errors.Wrap(err, "mapping GitHub to Slack users")
+	}
+	if len(slackUsers) == 0 {
+		return ghUserLink(ghUser), nil
+	}
+	return slackUserMention(slackUsers[0]), nil
+}
+
+func slackUserMention(slackID string) string {
+	return fmt.Sprintf("<@%s>", slackID)
+}
+
+func ghUserLink(u *github.User) string {
+	return fmt.Sprintf("<%s|%s>", u.GetHTMLURL(), u.GetLogin())
+}
+
+// isSlackError tells whether err is an error response from the Slack API with one of the given codes.
+func isSlackError(err error, codes ...string) bool {
+	var e slack.SlackErrorResponse
+	if !errors.As(err, &e) {
+		return false
+	}
+	for _, code := range codes {
+		if e.Err == code {
+			return true
+		}
+	}
+	return false
+}
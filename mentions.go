@@ -0,0 +1,257 @@
+package spreche
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// @-mentions are translated between GitHub and Slack using the UserStore:
+// @login in GitHub markdown becomes a Slack user mention,
+// and a Slack user mention becomes @login.
+// A Slack user with no known GitHub login is rendered by display name
+// (without an @, which might mention some unrelated GitHub user).
+
+// userMap maps between GitHub logins and Slack user IDs for the mentions in a single message.
+// It is built ahead of time (see Service.ghUserMap and Service.slackUserMap)
+// so that the conversion functions need no context and no error handling.
+// A nil *userMap translates nothing.
+type userMap struct {
+	slackIDs map[string]string // lowercased GitHub login -> Slack user ID
+	ghLogins map[string]string // Slack user ID -> GitHub login
+	names    map[string]string // Slack user ID -> display name, for Slack users with no GitHub login
+}
+
+func (m *userMap) add(u *User) {
+	if m.slackIDs == nil {
+		m.slackIDs = make(map[string]string)
+	}
+	if m.ghLogins == nil {
+		m.ghLogins = make(map[string]string)
+	}
+	m.slackIDs[strings.ToLower(u.GHLogin)] = u.SlackID
+	m.ghLogins[u.SlackID] = u.GHLogin
+}
+
+func (m *userMap) addName(slackID, name string) {
+	if m.names == nil {
+		m.names = make(map[string]string)
+	}
+	m.names[slackID] = name
+}
+
+// slackID gives the Slack user ID for a GitHub login,
+// or "" if there is none.
+func (m *userMap) slackID(login string) string {
+	if m == nil {
+		return ""
+	}
+	return m.slackIDs[strings.ToLower(login)]
+}
+
+// ghMention gives the GitHub markdown for mentioning a Slack user.
+// This is @login if the user has a GitHub login,
+// otherwise the user's (escaped) display name,
+// otherwise the bare user ID.
+func (m *userMap) ghMention(slackID string) string {
+	if m != nil {
+		if login, ok := m.ghLogins[slackID]; ok {
+			return "@" + login
+		}
+		if name, ok := m.names[slackID]; ok {
+			return ghEscape(name)
+		}
+	}
+	return slackID
+}
+
+// ghMentionRegex matches an @-mention in GitHub text.
+// The first subexpression is whatever precedes the @
+// (which must not be something that makes it part of an email address or a longer word),
+// and the second is the login.
+// A match followed by a / is a team mention (@org/team) and should be skipped.
+var ghMentionRegex = regexp.MustCompile(`(^|[^A-Za-z0-9_.@/\x60])@([A-Za-z0-9][A-Za-z0-9-]{0,38})`)
+
+// ghMentions gives the logins @-mentioned in GitHub text.
+func ghMentions(text string) []string {
+	var result []string
+	for _, m := range ghMentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		if m[1] < len(text) && text[m[1]] == '/' {
+			continue
+		}
+		result = append(result, strings.TrimRight(text[m[4]:m[5]], "-"))
+	}
+	return result
+}
+
+// slackMentionRegex matches a user mention in Slack mrkdwn,
+// <@U123> or <@U123|name>.
+// The subexpression is the user ID.
+var slackMentionRegex = regexp.MustCompile(`<@([UW][A-Z0-9]+)(?:\|[^>]*)?>`)
+
+// ghUserMap builds a userMap for the @-mentions in some GitHub markdown.
+func (s *Service) ghUserMap(ctx context.Context, tenantID int64, text string) (*userMap, error) {
+	m := new(userMap)
+	for _, login := range ghMentions(text) {
+		if m.slackID(login) != "" {
+			continue
+		}
+		u, err := s.Users.ByGHLogin(ctx, tenantID, login)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "looking up user %s", login)
+		}
+		m.add(u)
+	}
+	return m, nil
+}
+
+// slackUserMap builds a userMap for the user mentions in a Slack message,
+// given its mrkdwn text and its blocks.
+// Users with no GitHub login are looked up in Slack for their display names.
+func (s *Service) slackUserMap(ctx context.Context, tenant *Tenant, text string, blocks []slack.Block) (*userMap, error) {
+	var ids []string
+	for _, m := range slackMentionRegex.FindAllStringSubmatch(text, -1) {
+		ids = append(ids, m[1])
+	}
+	for _, block := range blocks {
+		if rblock, ok := block.(*slack.RichTextBlock); ok {
+			for _, elem := range rblock.Elements {
+				ids = append(ids, richTextUserIDs(elem)...)
+			}
+		}
+	}
+
+	var (
+		m  = new(userMap)
+		sc = tenant.SlackClient()
+	)
+	for _, id := range ids {
+		if _, ok := m.ghLogins[id]; ok {
+			continue
+		}
+		if _, ok := m.names[id]; ok {
+			continue
+		}
+		u, err := s.Users.BySlackID(ctx, tenant.TenantID, id)
+		if err == nil {
+			m.add(u)
+			continue
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, errors.Wrapf(err, "looking up user %s", id)
+		}
+		slackUser, err := sc.GetUserInfoContext(ctx, id)
+		if isSlackError(err, "user_not_found") {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting Slack info for user %s", id)
+		}
+		name := slackUser.Profile.DisplayName
+		if name == "" {
+			name = slackUser.RealName
+		}
+		if name == "" {
+			name = slackUser.Name
+		}
+		m.addName(id, name)
+	}
+	return m, nil
+}
+
+// richTextUserIDs gives the IDs of the users mentioned in a rich-text element.
+func richTextUserIDs(elem slack.RichTextElement) []string {
+	var result []string
+	switch elem := elem.(type) {
+	case *slack.RichTextSection:
+		for _, secElem := range elem.Elements {
+			if u, ok := secElem.(*slack.RichTextSectionUserElement); ok {
+				result = append(result, u.UserID)
+			}
+		}
+	case *rtList:
+		for _, sub := range elem.Elements {
+			result = append(result, richTextUserIDs(sub)...)
+		}
+	case *rtQuote:
+		for _, sub := range elem.Elements {
+			result = append(result, richTextUserIDs(sub)...)
+		}
+	}
+	return result
+}
+
+// slackMentionsToGH replaces the Slack user mentions in some (GitHub-escaped) mrkdwn.
+func slackMentionsToGH(text string, users *userMap) string {
+	return slackMentionRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := slackMentionRegex.FindStringSubmatch(s)
+		return users.ghMention(m[1])
+	})
+}
+
+// ghMentionsToSlack splits the text elements in a rich-text element
+// around any @-mentions of GitHub users with Slack user IDs,
+// replacing those with Slack user elements.
+// Code is left alone.
+func ghMentionsToSlack(elem slack.RichTextElement, users *userMap) {
+	switch elem := elem.(type) {
+	case *slack.RichTextSection:
+		var secElems []slack.RichTextSectionElement
+		for _, secElem := range elem.Elements {
+			t, ok := secElem.(*slack.RichTextSectionTextElement)
+			if !ok || (t.Style != nil && t.Style.Code) {
+				secElems = append(secElems, secElem)
+				continue
+			}
+			secElems = append(secElems, splitGHMentions(t, users)...)
+		}
+		elem.Elements = secElems
+
+	case *rtList:
+		for _, sub := range elem.Elements {
+			ghMentionsToSlack(sub, users)
+		}
+
+	case *rtQuote:
+		for _, sub := range elem.Elements {
+			ghMentionsToSlack(sub, users)
+		}
+	}
+}
+
+func splitGHMentions(t *slack.RichTextSectionTextElement, users *userMap) []slack.RichTextSectionElement {
+	var (
+		result []slack.RichTextSectionElement
+		text   = t.Text
+		pos    int
+	)
+	for _, m := range ghMentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		if m[1] < len(text) && text[m[1]] == '/' {
+			continue
+		}
+		login := strings.TrimRight(text[m[4]:m[5]], "-")
+		id := users.slackID(login)
+		if id == "" {
+			continue
+		}
+		start, end := m[4]-1, m[4]+len(login) // -1 for the @
+		if start > pos {
+			result = append(result, slack.NewRichTextSectionTextElement(text[pos:start], t.Style))
+		}
+		result = append(result, slack.NewRichTextSectionUserElement(id, t.Style))
+		pos = end
+	}
+	if pos == 0 {
+		return []slack.RichTextSectionElement{t}
+	}
+	if pos < len(text) {
+		result = append(result, slack.NewRichTextSectionTextElement(text[pos:], t.Style))
+	}
+	return result
+}
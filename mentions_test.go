@@ -0,0 +1,61 @@
+package spreche
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testUserMap is the userMap used by the golden tests.
+var testUserMap = &userMap{
+	slackIDs: map[string]string{"alice": "U0ALICE"},
+	ghLogins: map[string]string{"U0ALICE": "alice"},
+	names:    map[string]string{"U0CAROL": "Carol Q. Public"},
+}
+
+func TestGHMentions(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{{
+		in: "hey @alice", want: []string{"alice"},
+	}, {
+		in: "@alice and @bob-b, see this", want: []string{"alice", "bob-b"},
+	}, {
+		in: "mail alice@example.com", want: nil,
+	}, {
+		in: "cc @org/team", want: nil,
+	}, {
+		in: "(@alice-)", want: []string{"alice"},
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got := ghMentions(tc.in)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSlackMentionsToGH(t *testing.T) {
+	cases := []struct{ in, want string }{{
+		in: "hi <@U0ALICE>", want: "hi @alice",
+	}, {
+		in: "hi <@U0ALICE|alice.a>", want: "hi @alice",
+	}, {
+		in: "hi <@U0CAROL>", want: "hi Carol Q\\. Public",
+	}, {
+		in: "hi <@U0DAVE>", want: "hi U0DAVE",
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got := slackMentionsToGH(tc.in, testUserMap)
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/bobg/sqlutil"
+	"github.com/pkg/errors"
+
+	"spreche"
+)
+
+type botRuleStore struct {
+	db *sql.DB
+}
+
+var _ spreche.BotRuleStore = botRuleStore{}
+
+func (b botRuleStore) Add(ctx context.Context, rule *spreche.BotRule) error {
+	const q = `INSERT INTO bot_rules (tenant_id, allow, repo, bot, event_type, body_pattern) VALUES ($1, $2, $3, $4, $5, $6) RETURNING rule_id`
+	err := sqlutil.QueryRowContext(ctx, b.db, q, rule.TenantID, rule.Allow, rule.Repo, rule.Bot, rule.EventType, rule.BodyPattern).Scan(&rule.RuleID)
+	return errors.Wrap(err, "inserting bot rule row")
+}
+
+func (b botRuleStore) Remove(ctx context.Context, tenantID, ruleID int64) error {
+	const q = `DELETE FROM bot_rules WHERE tenant_id = $1 AND rule_id = $2`
+	res, err := b.db.ExecContext(ctx, q, tenantID, ruleID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "counting affected rows")
+	}
+	if n == 0 {
+		return spreche.ErrNotFound
+	}
+	return nil
+}
+
+func (b botRuleStore) Foreach(ctx context.Context, tenantID int64, f func(*spreche.BotRule) error) error {
+	const q = `SELECT rule_id, allow, repo, bot, event_type, body_pattern FROM bot_rules WHERE tenant_id = $1 ORDER BY rule_id`
+	return sqlutil.ForQueryRows(ctx, b.db, q, tenantID, func(ruleID int64, allow bool, repo, bot, eventType, bodyPattern string) error {
+		return f(&spreche.BotRule{
+			RuleID:      ruleID,
+			TenantID:    tenantID,
+			Allow:       allow,
+			Repo:        repo,
+			Bot:         bot,
+			EventType:   eventType,
+			BodyPattern: bodyPattern,
+		})
+	})
+}
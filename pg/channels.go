@@ -3,6 +3,7 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/bobg/sqlutil"
 	"github.com/google/go-github/v45/github"
@@ -18,33 +19,98 @@ type channelStore struct {
 var _ spreche.ChannelStore = channelStore{}
 
 func (c channelStore) Add(ctx context.Context, tenantID int64, channelID string, repo *github.Repository, prnum int, prBodyTS string) error {
-	const q = `INSERT INTO channels (tenant_id, channel_id, owner, repo, pr, prbody_timestamp) VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := c.db.ExecContext(ctx, q, tenantID, channelID, *repo.Owner.Login, *repo.Name, prnum, prBodyTS)
+	const q = `INSERT INTO channels (tenant_id, channel_id, owner, repo, pr, prbody_timestamp, setup_pending, state) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := c.db.ExecContext(ctx, q, tenantID, channelID, *repo.Owner.Login, *repo.Name, prnum, prBodyTS, prBodyTS == "", spreche.StateOpen)
 	return err
 }
 
 func (c channelStore) ByChannelID(ctx context.Context, tenantID int64, channelID string) (*spreche.Channel, error) {
-	const q = `SELECT owner, repo, pr, prbody_timestamp FROM channels WHERE tenant_id = $1 AND channel_id = $2`
-	result := &spreche.Channel{
-		ChannelID: channelID,
-	}
-	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, channelID).Scan(&result.Owner, &result.Repo, &result.PR, &result.PRBodyTS)
+	const q = `SELECT owner, repo, pr, prbody_timestamp, setup_pending, backfill_pending, state, closed_at, archived_at FROM channels WHERE tenant_id = $1 AND channel_id = $2`
+	var (
+		result = &spreche.Channel{
+			ChannelID: channelID,
+		}
+		closedAt, archivedAt sql.NullTime
+	)
+	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, channelID).Scan(&result.Owner, &result.Repo, &result.PR, &result.PRBodyTS, &result.SetupPending, &result.BackfillPending, &result.State, &closedAt, &archivedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		err = spreche.ErrNotFound
 	}
+	result.ClosedAt = closedAt.Time
+	result.ArchivedAt = archivedAt.Time
 	return result, err
 }
 
 func (c channelStore) ByRepoPR(ctx context.Context, tenantID int64, repo *github.Repository, prnum int) (*spreche.Channel, error) {
-	const q = `SELECT channel_id, prbody_timestamp FROM channels WHERE tenant_id = $1 AND owner = $2 AND repo = $3 AND pr = $4`
-	result := &spreche.Channel{
-		Owner: *repo.Owner.Login,
-		Repo:  *repo.Name,
-		PR:    prnum,
-	}
-	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, *repo.Owner.Login, *repo.Name, prnum).Scan(&result.ChannelID, &result.PRBodyTS)
+	const q = `SELECT channel_id, prbody_timestamp, setup_pending, backfill_pending, state, closed_at, archived_at FROM channels WHERE tenant_id = $1 AND owner = $2 AND repo = $3 AND pr = $4`
+	var (
+		result = &spreche.Channel{
+			Owner: *repo.Owner.Login,
+			Repo:  *repo.Name,
+			PR:    prnum,
+		}
+		closedAt, archivedAt sql.NullTime
+	)
+	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, *repo.Owner.Login, *repo.Name, prnum).Scan(&result.ChannelID, &result.PRBodyTS, &result.SetupPending, &result.BackfillPending, &result.State, &closedAt, &archivedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		err = spreche.ErrNotFound
 	}
+	result.ClosedAt = closedAt.Time
+	result.ArchivedAt = archivedAt.Time
 	return result, err
 }
+
+func (c channelStore) SetState(ctx context.Context, tenantID int64, channelID, state string, closedAt time.Time) error {
+	const q = `UPDATE channels SET state = $1, closed_at = $2 WHERE tenant_id = $3 AND channel_id = $4`
+	_, err := c.db.ExecContext(ctx, q, state, nullTime(closedAt), tenantID, channelID)
+	return err
+}
+
+func (c channelStore) SetPRBodyTS(ctx context.Context, tenantID int64, channelID, prBodyTS string) error {
+	const q = `UPDATE channels SET prbody_timestamp = $1, setup_pending = FALSE WHERE tenant_id = $2 AND channel_id = $3`
+	_, err := c.db.ExecContext(ctx, q, prBodyTS, tenantID, channelID)
+	return err
+}
+
+func (c channelStore) SetBackfillPending(ctx context.Context, tenantID int64, channelID string, pending bool) error {
+	const q = `UPDATE channels SET backfill_pending = $1 WHERE tenant_id = $2 AND channel_id = $3`
+	_, err := c.db.ExecContext(ctx, q, pending, tenantID, channelID)
+	return err
+}
+
+func (c channelStore) SetArchivedAt(ctx context.Context, tenantID int64, channelID string, archivedAt time.Time) error {
+	const q = `UPDATE channels SET archived_at = $1 WHERE tenant_id = $2 AND channel_id = $3`
+	_, err := c.db.ExecContext(ctx, q, nullTime(archivedAt), tenantID, channelID)
+	return err
+}
+
+func (c channelStore) ForeachByRepo(ctx context.Context, tenantID int64, owner, repo string, f func(*spreche.Channel) error) error {
+	const q = `SELECT channel_id, pr, prbody_timestamp, setup_pending, backfill_pending, state, closed_at, archived_at FROM channels WHERE tenant_id = $1 AND owner = $2 AND repo = $3`
+	return sqlutil.ForQueryRows(ctx, c.db, q, tenantID, owner, repo, func(channelID string, prnum int, prBodyTS string, setupPending, backfillPending bool, state string, closedAt, archivedAt sql.NullTime) error {
+		return f(&spreche.Channel{
+			ChannelID:       channelID,
+			Owner:           owner,
+			Repo:            repo,
+			PR:              prnum,
+			PRBodyTS:        prBodyTS,
+			SetupPending:    setupPending,
+			BackfillPending: backfillPending,
+			State:           state,
+			ClosedAt:        closedAt.Time,
+			ArchivedAt:      archivedAt.Time,
+		})
+	})
+}
+
+func (c channelStore) RenameRepo(ctx context.Context, tenantID int64, oldOwner, oldRepo, newOwner, newRepo string) error {
+	const q = `UPDATE channels SET owner = $1, repo = $2 WHERE tenant_id = $3 AND owner = $4 AND repo = $5`
+	_, err := c.db.ExecContext(ctx, q, newOwner, newRepo, tenantID, oldOwner, oldRepo)
+	return err
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
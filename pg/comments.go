@@ -17,12 +17,12 @@ type commentStore struct {
 var _ spreche.CommentStore = commentStore{}
 
 func (c commentStore) ByCommentID(ctx context.Context, tenantID int64, channelID string, commentID int64) (*spreche.Comment, error) {
-	const q = `SELECT thread_timestamp FROM comments WHERE tenant_id = $1 AND channel_id = $2 AND comment_id = $3`
+	const q = `SELECT thread_timestamp, kind FROM comments WHERE tenant_id = $1 AND channel_id = $2 AND comment_id = $3`
 	result := &spreche.Comment{
 		ChannelID: channelID,
 		CommentID: commentID,
 	}
-	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, channelID, commentID).Scan(&result.ThreadTimestamp)
+	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, channelID, commentID).Scan(&result.ThreadTimestamp, &result.Kind)
 	if errors.Is(err, sql.ErrNoRows) {
 		err = spreche.ErrNotFound
 	}
@@ -30,20 +30,20 @@ func (c commentStore) ByCommentID(ctx context.Context, tenantID int64, channelID
 }
 
 func (c commentStore) ByThreadTimestamp(ctx context.Context, tenantID int64, channelID, timestamp string) (*spreche.Comment, error) {
-	const q = `SELECT comment_id FROM comments WHERE tenant_id = $1 AND channel_id = $2 AND thread_timestamp = $3`
+	const q = `SELECT comment_id, kind FROM comments WHERE tenant_id = $1 AND channel_id = $2 AND thread_timestamp = $3`
 	result := &spreche.Comment{
 		ChannelID:       channelID,
 		ThreadTimestamp: timestamp,
 	}
-	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, channelID, timestamp).Scan(&result.CommentID)
+	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, channelID, timestamp).Scan(&result.CommentID, &result.Kind)
 	if errors.Is(err, sql.ErrNoRows) {
 		err = spreche.ErrNotFound
 	}
 	return result, err
 }
 
-func (c commentStore) Add(ctx context.Context, tenantID int64, channelID, timestamp string, commentID int64) error {
-	const q = `INSERT INTO comments (tenant_id, channel_id, thread_timestamp, comment_id) VALUES ($1, $2, $3, $4)`
-	_, err := c.db.ExecContext(ctx, q, tenantID, channelID, timestamp, commentID)
+func (c commentStore) Add(ctx context.Context, tenantID int64, channelID, timestamp string, commentID int64, kind string) error {
+	const q = `INSERT INTO comments (tenant_id, channel_id, thread_timestamp, comment_id, kind) VALUES ($1, $2, $3, $4, $5)`
+	_, err := c.db.ExecContext(ctx, q, tenantID, channelID, timestamp, commentID, kind)
 	return err
 }
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS jobs (
+  job_id SERIAL NOT NULL PRIMARY KEY,
+  kind TEXT NOT NULL,
+  event_type TEXT NOT NULL,
+  payload BYTEA NOT NULL,
+  state TEXT NOT NULL,
+  attempts INTEGER NOT NULL,
+  run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  last_error TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS jobs_state_run_at_index ON jobs (state, run_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE jobs;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE channels ADD COLUMN state TEXT NOT NULL DEFAULT 'open';
+ALTER TABLE channels ALTER COLUMN state DROP DEFAULT;
+ALTER TABLE channels ADD COLUMN closed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE channels ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE channels DROP COLUMN archived_at;
+ALTER TABLE channels DROP COLUMN closed_at;
+ALTER TABLE channels DROP COLUMN state;
+-- +goose StatementEnd
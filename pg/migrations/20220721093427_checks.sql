@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS checks (
+  tenant_id INTEGER NOT NULL,
+  channel_id TEXT NOT NULL,
+  sha TEXT NOT NULL,
+  timestamp TEXT NOT NULL,
+  PRIMARY KEY (tenant_id, channel_id, sha)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE checks;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS team_groups (
+  tenant_id INTEGER NOT NULL,
+  gh_org TEXT NOT NULL,
+  gh_team TEXT NOT NULL,
+  slack_group_id TEXT NOT NULL,
+  PRIMARY KEY (tenant_id, gh_org, gh_team)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE team_groups;
+-- +goose StatementEnd
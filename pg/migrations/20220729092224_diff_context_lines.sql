@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tenants ADD COLUMN diff_context_lines INTEGER NOT NULL DEFAULT 3;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tenants DROP COLUMN diff_context_lines;
+-- +goose StatementEnd
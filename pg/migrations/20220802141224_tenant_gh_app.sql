@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing tenants belong to the app whose ID was formerly hardcoded.
+ALTER TABLE tenants ADD COLUMN gh_app_id INTEGER NOT NULL DEFAULT 207677;
+ALTER TABLE tenants ADD COLUMN gh_webhook_secret TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tenants DROP COLUMN gh_webhook_secret;
+ALTER TABLE tenants DROP COLUMN gh_app_id;
+-- +goose StatementEnd
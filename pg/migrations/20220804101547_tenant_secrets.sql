@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tenant_secrets (
+  secret_id SERIAL NOT NULL PRIMARY KEY,
+  tenant_id INTEGER NOT NULL REFERENCES tenants (tenant_id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS tenant_secrets_tenant_kind_index ON tenant_secrets (tenant_id, kind);
+
+INSERT INTO tenant_secrets (tenant_id, kind, secret, created_at)
+  SELECT tenant_id, 'github_webhook', gh_webhook_secret, CURRENT_TIMESTAMP FROM tenants WHERE gh_webhook_secret != '';
+
+ALTER TABLE tenants DROP COLUMN gh_webhook_secret;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tenants ADD COLUMN gh_webhook_secret TEXT NOT NULL DEFAULT '';
+
+UPDATE tenants SET gh_webhook_secret = COALESCE((
+  SELECT secret FROM tenant_secrets s
+    WHERE s.tenant_id = tenants.tenant_id AND s.kind = 'github_webhook'
+    ORDER BY created_at DESC LIMIT 1
+), '');
+
+DROP TABLE tenant_secrets;
+-- +goose StatementEnd
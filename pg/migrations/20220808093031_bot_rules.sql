@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS bot_rules (
+  rule_id SERIAL NOT NULL PRIMARY KEY,
+  tenant_id INTEGER NOT NULL REFERENCES tenants (tenant_id) ON DELETE CASCADE,
+  allow BOOLEAN NOT NULL,
+  repo TEXT NOT NULL DEFAULT '',
+  bot TEXT NOT NULL DEFAULT '',
+  event_type TEXT NOT NULL DEFAULT '',
+  body_pattern TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS bot_rules_tenant_index ON bot_rules (tenant_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE bot_rules;
+-- +goose StatementEnd
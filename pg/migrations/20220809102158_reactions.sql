@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS reactions (
+  tenant_id INTEGER NOT NULL,
+  channel_id TEXT NOT NULL,
+  timestamp TEXT NOT NULL,
+  slack_user_id TEXT NOT NULL,
+  slack_reaction TEXT NOT NULL,
+  target TEXT NOT NULL,
+  target_id BIGINT NOT NULL,
+  reaction_id BIGINT NOT NULL,
+  PRIMARY KEY (tenant_id, channel_id, timestamp, slack_user_id, slack_reaction)
+);
+
+CREATE INDEX IF NOT EXISTS reactions_reaction_id_index ON reactions (tenant_id, reaction_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE reactions;
+-- +goose StatementEnd
@@ -25,19 +25,19 @@ var _ spreche.TenantStore = tenantStore{}
 func (t tenantStore) WithTenant(ctx context.Context, tenantID int64, repoURL, teamID string, f func(context.Context, *spreche.Tenant) error) error {
 	const (
 		qTenantID = `
-			SELECT tenant_id, gh_installation_id, gh_priv_key, gh_api_url, gh_upload_url, slack_token
+			SELECT tenant_id, gh_installation_id, gh_priv_key, gh_api_url, gh_upload_url, gh_app_id, slack_token, state, defer_drafts, mark_resolved, diff_context_lines, delete_policy, proxy_images
 				FROM tenants
 				WHERE tenant_id = $1
 		`
 		qRepo = `
-			SELECT r.tenant_id, t.gh_installation_id, t.gh_priv_key, t.gh_api_url, t.gh_upload_url, t.slack_token
+			SELECT r.tenant_id, t.gh_installation_id, t.gh_priv_key, t.gh_api_url, t.gh_upload_url, t.gh_app_id, t.slack_token, t.state, t.defer_drafts, t.mark_resolved, t.diff_context_lines, t.delete_policy, t.proxy_images
 				FROM tenant_repos r, tenants t
-				WHERE r.tenant_id = t.tenant_id AND r.gh_url = $1
+				WHERE r.tenant_id = t.tenant_id AND r.gh_url = $1 AND t.state = 'active'
 		`
 		qTeam = `
-			SELECT tt.tenant_id, t.gh_installation_id, t.gh_priv_key, t.gh_api_url, t.gh_upload_url, t.slack_token
+			SELECT tt.tenant_id, t.gh_installation_id, t.gh_priv_key, t.gh_api_url, t.gh_upload_url, t.gh_app_id, t.slack_token, t.state, t.defer_drafts, t.mark_resolved, t.diff_context_lines, t.delete_policy, t.proxy_images
 				FROM tenant_teams tt, tenants t
-				WHERE tt.tenant_id = t.tenant_id AND tt.team_id = $1
+				WHERE tt.tenant_id = t.tenant_id AND tt.team_id = $1 AND t.state = 'active'
 		`
 	)
 
@@ -60,43 +60,25 @@ func (t tenantStore) WithTenant(ctx context.Context, tenantID int64, repoURL, te
 
 	var tenant spreche.Tenant
 
-	err := sqlutil.QueryRowContext(ctx, t.db, q, arg).Scan(
-		&tenant.TenantID,
-		&tenant.GHInstallationID,
-		&tenant.GHPrivKey,
-		&tenant.GHAPIURL,
-		&tenant.GHUploadURL,
-		&tenant.SlackToken,
-	)
+	err := sqlutil.QueryRowContext(ctx, t.db, q, arg).Scan(tenantDest(&tenant)...)
 	if isRepo && errors.Is(err, sql.ErrNoRows) {
-		u, err := url.Parse(repoURL)
-		if err != nil {
-			return errors.Wrapf(err, "parsing URL %s", repoURL)
+		u, parseErr := url.Parse(repoURL)
+		if parseErr != nil {
+			return errors.Wrapf(parseErr, "parsing URL %s", repoURL)
 		}
 		u.Path = path.Dir(u.Path)
-		err = sqlutil.QueryRowContext(ctx, t.db, q, u.String()).Scan(
-			&tenant.TenantID,
-			&tenant.GHInstallationID,
-			&tenant.GHPrivKey,
-			&tenant.GHAPIURL,
-			&tenant.GHUploadURL,
-			&tenant.SlackToken,
-		)
+		err = sqlutil.QueryRowContext(ctx, t.db, q, u.String()).Scan(tenantDest(&tenant)...)
 		if errors.Is(err, sql.ErrNoRows) {
 			// One more time.
 			u.Path = path.Dir(u.Path)
-			err = sqlutil.QueryRowContext(ctx, t.db, q, u.String()).Scan(
-				&tenant.TenantID,
-				&tenant.GHInstallationID,
-				&tenant.GHPrivKey,
-				&tenant.GHAPIURL,
-				&tenant.GHUploadURL,
-				&tenant.SlackToken,
-			)
+			err = sqlutil.QueryRowContext(ctx, t.db, q, u.String()).Scan(tenantDest(&tenant)...)
 			// Fall through to the err check below.
 		}
 		// Fall through to the err check below.
 	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return spreche.ErrNotFound
+	}
 	if err != nil {
 		return errors.Wrap(err, "getting tenant")
 	}
@@ -104,9 +86,31 @@ func (t tenantStore) WithTenant(ctx context.Context, tenantID int64, repoURL, te
 	return f(ctx, &tenant)
 }
 
+// tenantDest gives the scan destinations for the tenant columns selected in WithTenant.
+func tenantDest(tenant *spreche.Tenant) []any {
+	return []any{
+		&tenant.TenantID,
+		&tenant.GHInstallationID,
+		&tenant.GHPrivKey,
+		&tenant.GHAPIURL,
+		&tenant.GHUploadURL,
+		&tenant.GHAppID,
+		&tenant.SlackToken,
+		&tenant.State,
+		&tenant.DeferDrafts,
+		&tenant.MarkResolved,
+		&tenant.DiffContextLines,
+		&tenant.DeletePolicy,
+		&tenant.ProxyImages,
+	}
+}
+
 func (t tenantStore) Add(ctx context.Context, vals *spreche.Tenant) error {
-	const q = `INSERT INTO tenants (gh_installation_id, gh_priv_key, gh_api_url, gh_upload_url, slack_token) VALUES ($1, $2, $3, $4, $5)`
-	res, err := t.db.ExecContext(ctx, q, vals.GHInstallationID, vals.GHPrivKey, vals.GHAPIURL, vals.GHUploadURL, vals.SlackToken)
+	if vals.State == "" {
+		vals.State = spreche.TenantActive
+	}
+	const q = `INSERT INTO tenants (gh_installation_id, gh_priv_key, gh_api_url, gh_upload_url, gh_app_id, slack_token, state) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	res, err := t.db.ExecContext(ctx, q, vals.GHInstallationID, vals.GHPrivKey, vals.GHAPIURL, vals.GHUploadURL, vals.GHAppID, vals.SlackToken, vals.State)
 	if err != nil {
 		return errors.Wrap(err, "inserting tenant row")
 	}
@@ -142,12 +146,30 @@ func (t tenantStore) Add(ctx context.Context, vals *spreche.Tenant) error {
 	return nil
 }
 
+func (t tenantStore) Update(ctx context.Context, vals *spreche.Tenant) error {
+	const q = `UPDATE tenants SET gh_app_id = $1, slack_token = $2, state = $3, defer_drafts = $4, mark_resolved = $5, diff_context_lines = $6, delete_policy = $7, proxy_images = $8 WHERE tenant_id = $9`
+	_, err := t.db.ExecContext(ctx, q, vals.GHAppID, vals.SlackToken, vals.State, vals.DeferDrafts, vals.MarkResolved, vals.DiffContextLines, vals.DeletePolicy, vals.ProxyImages, vals.TenantID)
+	return err
+}
+
 func (t tenantStore) AddGHURL(ctx context.Context, tenantID int64, ghURL string) error {
 	const q = `INSERT INTO tenant_repos (tenant_id, gh_url) VALUES ($1, $2)`
 	_, err := t.db.ExecContext(ctx, q, tenantID, ghURL)
 	return err
 }
 
+func (t tenantStore) RemoveGHURL(ctx context.Context, tenantID int64, ghURL string) error {
+	const q = `DELETE FROM tenant_repos WHERE tenant_id = $1 AND gh_url = $2`
+	_, err := t.db.ExecContext(ctx, q, tenantID, ghURL)
+	return err
+}
+
+func (t tenantStore) RenameGHURL(ctx context.Context, tenantID int64, oldURL, newURL string) error {
+	const q = `UPDATE tenant_repos SET gh_url = $1 WHERE tenant_id = $2 AND gh_url = $3`
+	_, err := t.db.ExecContext(ctx, q, newURL, tenantID, oldURL)
+	return err
+}
+
 func (t tenantStore) AddTeam(ctx context.Context, tenantID int64, teamID string) error {
 	const q = `INSERT INTO tenant_teams (tenant_id, team_id) VALUES ($1, $2)`
 	_, err := t.db.ExecContext(ctx, q, tenantID, teamID)
@@ -155,33 +177,60 @@ func (t tenantStore) AddTeam(ctx context.Context, tenantID int64, teamID string)
 }
 
 func (t tenantStore) Foreach(ctx context.Context, f func(*spreche.Tenant) error) error {
-	const q = `SELECT tenant_id, gh_installation_id, gh_priv_key, gh_api_url, gh_upload_url, slack_token FROM tenants`
-	return sqlutil.ForQueryRows(ctx, t.db, q, func(tenantID, ghInstallationID int64, ghPrivKey []byte, ghAPIURL, ghUploadURL, slackToken string) error {
+	const q = `SELECT tenant_id, gh_installation_id, gh_priv_key, gh_api_url, gh_upload_url, gh_app_id, slack_token, state, defer_drafts, mark_resolved, diff_context_lines, delete_policy, proxy_images FROM tenants`
+	return sqlutil.ForQueryRows(ctx, t.db, q, func(tenantID, ghInstallationID int64, ghPrivKey []byte, ghAPIURL, ghUploadURL string, ghAppID int64, slackToken, state string, deferDrafts, markResolved bool, diffContextLines int, deletePolicy string, proxyImages bool) error {
 		var tenant = &spreche.Tenant{
 			TenantID:         tenantID,
 			GHInstallationID: ghInstallationID,
 			GHPrivKey:        ghPrivKey,
 			GHAPIURL:         ghAPIURL,
 			GHUploadURL:      ghUploadURL,
+			GHAppID:          ghAppID,
 			SlackToken:       slackToken,
+			State:            state,
+			DeferDrafts:      deferDrafts,
+			MarkResolved:     markResolved,
+			DiffContextLines: diffContextLines,
+			DeletePolicy:     deletePolicy,
+			ProxyImages:      proxyImages,
 		}
-
-		const qRepos = `SELECT gh_url FROM tenant_repos WHERE tenant_id = $1`
-		err := sqlutil.ForQueryRows(ctx, t.db, qRepos, tenantID, func(ghURL string) {
-			tenant.GHURLs = append(tenant.GHURLs, ghURL)
-		})
-		if err != nil {
-			return errors.Wrap(err, "getting GitHub URLs")
+		if err := t.getLists(ctx, tenant); err != nil {
+			return err
 		}
+		return f(tenant)
+	})
+}
 
-		const qTeams = `SELECT team_id FROM tenant_teams WHERE tenant_id = $1`
-		err = sqlutil.ForQueryRows(ctx, t.db, qTeams, tenantID, func(teamID string) {
-			tenant.TeamIDs = append(tenant.TeamIDs, teamID)
-		})
-		if err != nil {
-			return errors.Wrap(err, "getting team IDs")
-		}
+func (t tenantStore) ByGHInstallation(ctx context.Context, apiURL string, appID, installationID int64) (*spreche.Tenant, error) {
+	const q = `
+		SELECT tenant_id, gh_installation_id, gh_priv_key, gh_api_url, gh_upload_url, gh_app_id, slack_token, state, defer_drafts, mark_resolved, diff_context_lines, delete_policy, proxy_images
+			FROM tenants
+			WHERE gh_api_url = $1 AND gh_app_id = $2 AND gh_installation_id = $3
+	`
+	var tenant spreche.Tenant
+	err := sqlutil.QueryRowContext(ctx, t.db, q, apiURL, appID, installationID).Scan(tenantDest(&tenant)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, spreche.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting tenant")
+	}
+	return &tenant, t.getLists(ctx, &tenant)
+}
 
-		return f(tenant)
+// getLists populates the GHURLs and TeamIDs fields of a tenant.
+func (t tenantStore) getLists(ctx context.Context, tenant *spreche.Tenant) error {
+	const qRepos = `SELECT gh_url FROM tenant_repos WHERE tenant_id = $1`
+	err := sqlutil.ForQueryRows(ctx, t.db, qRepos, tenant.TenantID, func(ghURL string) {
+		tenant.GHURLs = append(tenant.GHURLs, ghURL)
+	})
+	if err != nil {
+		return errors.Wrap(err, "getting GitHub URLs")
+	}
+
+	const qTeams = `SELECT team_id FROM tenant_teams WHERE tenant_id = $1`
+	err = sqlutil.ForQueryRows(ctx, t.db, qTeams, tenant.TenantID, func(teamID string) {
+		tenant.TeamIDs = append(tenant.TeamIDs, teamID)
 	})
+	return errors.Wrap(err, "getting team IDs")
 }
@@ -0,0 +1,265 @@
+package spreche_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bobg/mid"
+
+	"spreche"
+	"spreche/sqlite"
+)
+
+const (
+	testAppID       = 17
+	testInstID      = 42
+	testGHSecret    = "gh-secret"
+	testSlackSecret = "slack-secret"
+	testChannelID   = "C0PRCHAN"
+)
+
+// TestProvisioningFlow follows a tenant from the installation of the GitHub App,
+// through its completion by an operator,
+// to verified webhooks and events from both sides,
+// none of which may need the service-wide fallback secrets.
+func TestProvisioningFlow(t *testing.T) {
+	ctx := context.Background()
+	s, apiURL, count := newTestService(t)
+
+	// A new installation creates a pending tenant.
+	install := installationEvent(apiURL, "created")
+	if code := postGHWebhook(t, s, "installation", install); code >= 300 {
+		t.Fatalf("got status %d for installation webhook", code)
+	}
+	if err := s.RunJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tenant, err := s.Tenants.ByGHInstallation(ctx, apiURL+"/api/v3/", testAppID, testInstID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant.State != spreche.TenantPending {
+		t.Fatalf("got tenant state %s, want %s", tenant.State, spreche.TenantPending)
+	}
+
+	// The operator attaches a Slack team and its signing secret.
+	cmd := spreche.AdminCmd{
+		Key:  "admin-key",
+		Args: []string{"tenant", "complete", "-tenant", strconv.FormatInt(tenant.TenantID, 10), "-slacktoken", "xoxb-fake", "-slacksecret", testSlackSecret},
+	}
+	if code := postJSON(t, mid.JSON(s.OnAdmin(nil, nil)), "/admin", cmd, nil); code >= 300 {
+		t.Fatalf("got status %d for tenant complete", code)
+	}
+
+	// Opening a PR creates its channel.
+	pr := map[string]any{
+		"action": "opened",
+		"number": 6,
+		"pull_request": map[string]any{
+			"number":   6,
+			"title":    "Fix things",
+			"html_url": apiURL + "/bobg/spreche/pull/6",
+			"state":    "open",
+			"user":     map[string]any{"login": "bobg"},
+			"head":     map[string]any{"sha": "abc123"},
+		},
+		"repository":   testRepo(apiURL),
+		"installation": map[string]any{"id": testInstID},
+		"sender":       map[string]any{"login": "bobg", "html_url": apiURL + "/bobg"},
+	}
+	if code := postGHWebhook(t, s, "pull_request", pr); code >= 300 {
+		t.Fatalf("got status %d for pull_request webhook", code)
+	}
+	if err = s.RunJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("POST /slack/conversations.create"); n != 1 {
+		t.Fatalf("got %d channels created, want 1", n)
+	}
+
+	// A message in the channel becomes a PR comment.
+	msg := map[string]any{
+		"type":     "event_callback",
+		"team_id":  "T0TEAM",
+		"event_id": "Ev1",
+		"event": map[string]any{
+			"type":         "message",
+			"user":         "U0ALICE",
+			"text":         "Looks good",
+			"ts":           "2.000000",
+			"channel":      testChannelID,
+			"channel_type": "channel",
+		},
+	}
+	if code := postSlackEvent(t, s, msg, s.SlackSigningSecret); code != http.StatusUnauthorized {
+		t.Errorf("got status %d for Slack event signed with the global secret, want %d", code, http.StatusUnauthorized)
+	}
+	if code := postSlackEvent(t, s, msg, testSlackSecret); code >= 300 {
+		t.Fatalf("got status %d for Slack event", code)
+	}
+	if err = s.RunJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("POST /api/v3/repos/bobg/spreche/issues/6/comments"); n != 1 {
+		t.Errorf("got %d PR comments, want 1", n)
+	}
+}
+
+// TestInstallationDeleted checks that uninstalling the GitHub App
+// disables the tenant created when it was installed.
+func TestInstallationDeleted(t *testing.T) {
+	ctx := context.Background()
+	s, apiURL, _ := newTestService(t)
+
+	for _, action := range []string{"created", "deleted"} {
+		if code := postGHWebhook(t, s, "installation", installationEvent(apiURL, action)); code >= 300 {
+			t.Fatalf("got status %d for installation %s webhook", code, action)
+		}
+		if err := s.RunJobs(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tenant, err := s.Tenants.ByGHInstallation(ctx, apiURL+"/api/v3/", testAppID, testInstID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant.State != spreche.TenantDisabled {
+		t.Errorf("got tenant state %s, want %s", tenant.State, spreche.TenantDisabled)
+	}
+
+	// A webhook whose app ID header disagrees with its payload is rejected.
+	ev := installationEvent(apiURL, "unsuspend")
+	ev["installation"].(map[string]any)["app_id"] = testAppID + 1
+	if code := postGHWebhook(t, s, "installation", ev); code != http.StatusBadRequest {
+		t.Errorf("got status %d for webhook with mismatched app IDs, want %d", code, http.StatusBadRequest)
+	}
+}
+
+// newTestService produces a Service with sqlite stores,
+// talking to a fake GitHub and Slack API.
+// It does not use fallback secrets.
+// It also returns the fake API's URL,
+// which doubles as the URL of the GitHub server,
+// and a function counting the requests received by the fake API.
+func newTestService(t *testing.T) (*spreche.Service, string, func(string) int) {
+	t.Helper()
+
+	stores, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "spreche.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stores.Close() })
+
+	apiURL, count := spreche.NewFakeAPI(t, testChannelID)
+
+	s := &spreche.Service{
+		AdminKey:           "admin-key",
+		GHSecret:           testGHSecret,
+		SlackSigningSecret: "global-slack-secret",
+		GHAppID:            testAppID,
+		GHPrivKey:          spreche.FakePrivKey(t),
+
+		BotRules:   stores.BotRules,
+		Channels:   stores.Channels,
+		Checks:     stores.Checks,
+		Comments:   stores.Comments,
+		Deliveries: stores.Deliveries,
+		Groups:     stores.Groups,
+		Jobs:       stores.Jobs,
+		Reactions:  stores.Reactions,
+		Secrets:    stores.Secrets,
+		Tenants:    stores.Tenants,
+		Users:      stores.Users,
+	}
+	return s, apiURL, count
+}
+
+func installationEvent(apiURL, action string) map[string]any {
+	return map[string]any{
+		"action": action,
+		"installation": map[string]any{
+			"id":                   testInstID,
+			"app_id":               testAppID,
+			"repository_selection": "all",
+			"account":              map[string]any{"login": "bobg", "html_url": apiURL + "/bobg"},
+		},
+		"repositories": []any{map[string]any{"full_name": "bobg/spreche"}},
+		"sender":       map[string]any{"login": "bobg", "html_url": apiURL + "/bobg"},
+	}
+}
+
+func testRepo(apiURL string) map[string]any {
+	return map[string]any{
+		"name":      "spreche",
+		"full_name": "bobg/spreche",
+		"html_url":  apiURL + "/bobg/spreche",
+		"owner":     map[string]any{"login": "bobg"},
+	}
+}
+
+// postGHWebhook sends a GitHub webhook for the test app to s,
+// signed with the app's webhook secret.
+// It returns the HTTP status of the response.
+func postGHWebhook(t *testing.T, s *spreche.Service, event string, payload any) int {
+	t.Helper()
+
+	return postJSON(t, mid.Err(s.OnGHWebhook), "/github", payload, func(req *http.Request, body []byte) {
+		mac := hmac.New(sha256.New, []byte(testGHSecret))
+		mac.Write(body)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-GitHub-Delivery", fmt.Sprintf("delivery-%d", time.Now().UnixNano()))
+		req.Header.Set("X-GitHub-Hook-Installation-Target-Type", "integration")
+		req.Header.Set("X-GitHub-Hook-Installation-Target-ID", strconv.Itoa(testAppID))
+	})
+}
+
+// postSlackEvent sends a Slack event to s,
+// signed with the given secret.
+// It returns the HTTP status of the response.
+func postSlackEvent(t *testing.T, s *spreche.Service, payload any, secret string) int {
+	t.Helper()
+
+	return postJSON(t, mid.Err(s.OnSlackEvent), "/slack/event", payload, func(req *http.Request, body []byte) {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	})
+}
+
+// postJSON sends payload as a JSON request to the given handler,
+// after letting prepare (if non-nil) add headers.
+// It returns the HTTP status of the response.
+func postJSON(t *testing.T, h http.Handler, path string, payload any, prepare func(*http.Request, []byte)) int {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if prepare != nil {
+		prepare(req, body)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code >= 300 {
+		t.Logf("%s: %s", path, rec.Body)
+	}
+	return rec.Code
+}
@@ -0,0 +1,201 @@
+package spreche
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Reactions to messages in PR channels are mirrored as GitHub reactions:
+// on the PR itself for the status card,
+// otherwise on the comment behind the message.
+// Only the emoji with GitHub counterparts are mirrored (see slackToGHReactions).
+//
+// GitHub lets an app react only as itself,
+// so a reaction is made by the app,
+// whether or not the reacting Slack user has a linked GitHub login.
+// Several Slack users' reactions may therefore amount to one GitHub reaction,
+// which is removed only when the last of them is.
+
+// ReactionStore is a persistent store associating Slack reactions with the GitHub reactions mirroring them.
+type ReactionStore interface {
+	// Add adds a reaction record.
+	Add(context.Context, int64, *Reaction) error
+
+	// Get gets the record of a Slack user's reaction to a message.
+	// If there is none it returns ErrNotFound.
+	Get(ctx context.Context, tenantID int64, channelID, timestamp, slackUserID, slackReaction string) (*Reaction, error)
+
+	// Remove removes the record of a Slack user's reaction to a message,
+	// returning it together with the number of records remaining for the same GitHub reaction.
+	// The removal and the count happen in one transaction,
+	// so when the last records for a GitHub reaction are removed concurrently,
+	// exactly one of the removals sees zero remaining.
+	// If there is no such record it returns ErrNotFound.
+	Remove(ctx context.Context, tenantID int64, channelID, timestamp, slackUserID, slackReaction string) (*Reaction, int, error)
+}
+
+type Reaction struct {
+	ChannelID     string
+	Timestamp     string // of the Slack message reacted to
+	SlackUserID   string
+	SlackReaction string // the Slack emoji name
+
+	Target     string // one of the ReactionTarget constants
+	TargetID   int64  // the PR number or comment ID
+	ReactionID int64  // the GitHub reaction
+}
+
+// Kinds of GitHub object reacted to.
+const (
+	ReactionTargetPR            = "pr"
+	ReactionTargetIssueComment  = "issue_comment"
+	ReactionTargetReviewComment = "review_comment"
+)
+
+// slackToGHReactions maps Slack emoji names to GitHub reaction contents.
+var slackToGHReactions = map[string]string{
+	"+1":         "+1",
+	"thumbsup":   "+1",
+	"-1":         "-1",
+	"thumbsdown": "-1",
+	"laughing":   "laugh",
+	"satisfied":  "laugh",
+	"smile":      "laugh",
+	"joy":        "laugh",
+	"confused":   "confused",
+	"heart":      "heart",
+	"tada":       "hooray",
+	"rocket":     "rocket",
+	"eyes":       "eyes",
+}
+
+// ghReactionContent gives the GitHub reaction content for a Slack emoji name,
+// ignoring any skin-tone modifier.
+func ghReactionContent(slackReaction string) (string, bool) {
+	name, _, _ := strings.Cut(slackReaction, "::")
+	content, ok := slackToGHReactions[name]
+	return content, ok
+}
+
+func (s *Service) OnReactionAdded(ctx context.Context, tenant *Tenant, gh *github.Client, ev *slackevents.ReactionAddedEvent) error {
+	if ev.Item.Type != "message" {
+		return nil
+	}
+	content, ok := ghReactionContent(ev.Reaction)
+	if !ok {
+		debugf("No GitHub reaction for Slack reaction %s", ev.Reaction)
+		return nil
+	}
+
+	channel, err := s.Channels.ByChannelID(ctx, tenant.TenantID, ev.Item.Channel)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting info for channelID %s", ev.Item.Channel)
+	}
+
+	rec := &Reaction{
+		ChannelID:     channel.ChannelID,
+		Timestamp:     ev.Item.Timestamp,
+		SlackUserID:   ev.User,
+		SlackReaction: ev.Reaction,
+	}
+
+	var reaction *github.Reaction
+
+	if ev.Item.Timestamp == channel.PRBodyTS {
+		rec.Target, rec.TargetID = ReactionTargetPR, int64(channel.PR)
+		reaction, _, err = gh.Reactions.CreateIssueReaction(ctx, channel.Owner, channel.Repo, channel.PR, content)
+	} else {
+		var comment *Comment
+		comment, err = s.Comments.ByThreadTimestamp(ctx, tenant.TenantID, channel.ChannelID, ev.Item.Timestamp)
+		if errors.Is(err, ErrNotFound) {
+			debugf("No comment for message %s in channel %s", ev.Item.Timestamp, channel.ChannelID)
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "getting comment for message %s", ev.Item.Timestamp)
+		}
+
+		rec.TargetID = comment.CommentID
+		err = tryCommentKinds(comment, func(kind string) error {
+			var err error
+			switch kind {
+			case CommentKindIssueComment:
+				rec.Target = ReactionTargetIssueComment
+				reaction, _, err = gh.Reactions.CreateIssueCommentReaction(ctx, channel.Owner, channel.Repo, comment.CommentID, content)
+			case CommentKindReviewComment:
+				rec.Target = ReactionTargetReviewComment
+				reaction, _, err = gh.Reactions.CreatePullRequestCommentReaction(ctx, channel.Owner, channel.Repo, comment.CommentID, content)
+			}
+			// Other kinds (i.e. reviews) cannot have reactions.
+			return err
+		})
+		if reaction == nil && (err == nil || isGHNotFound(err)) {
+			debugf("Comment %d cannot have reactions", comment.CommentID)
+			return nil
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "adding %s reaction", content)
+	}
+
+	rec.ReactionID = reaction.GetID()
+	err = s.Reactions.Add(ctx, tenant.TenantID, rec)
+	return errors.Wrap(err, "adding reaction record")
+}
+
+func (s *Service) OnReactionRemoved(ctx context.Context, tenant *Tenant, gh *github.Client, ev *slackevents.ReactionRemovedEvent) error {
+	if ev.Item.Type != "message" {
+		return nil
+	}
+
+	rec, n, err := s.Reactions.Remove(ctx, tenant.TenantID, ev.Item.Channel, ev.Item.Timestamp, ev.User, ev.Reaction)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "removing reaction record")
+	}
+	if n > 0 {
+		debugf("Reaction %d still has %d other Slack reactions", rec.ReactionID, n)
+		return nil
+	}
+
+	err = s.deleteGHReaction(ctx, tenant, gh, rec)
+	if err != nil {
+		// Restore the record so that a retry of this event finds it.
+		if err2 := s.Reactions.Add(ctx, tenant.TenantID, rec); err2 != nil {
+			log.Printf("Error restoring record of reaction %d: %s", rec.ReactionID, err2)
+		}
+	}
+	return err
+}
+
+// deleteGHReaction deletes the GitHub reaction of a reaction record.
+// It is not an error if the reaction is already gone.
+func (s *Service) deleteGHReaction(ctx context.Context, tenant *Tenant, gh *github.Client, rec *Reaction) error {
+	channel, err := s.Channels.ByChannelID(ctx, tenant.TenantID, rec.ChannelID)
+	if err != nil {
+		return errors.Wrapf(err, "getting info for channelID %s", rec.ChannelID)
+	}
+
+	switch rec.Target {
+	case ReactionTargetPR:
+		_, err = gh.Reactions.DeleteIssueReaction(ctx, channel.Owner, channel.Repo, int(rec.TargetID), rec.ReactionID)
+	case ReactionTargetIssueComment:
+		_, err = gh.Reactions.DeleteIssueCommentReaction(ctx, channel.Owner, channel.Repo, rec.TargetID, rec.ReactionID)
+	case ReactionTargetReviewComment:
+		_, err = gh.Reactions.DeletePullRequestCommentReaction(ctx, channel.Owner, channel.Repo, rec.TargetID, rec.ReactionID)
+	}
+	if err != nil && !isGHNotFound(err) {
+		return errors.Wrapf(err, "deleting reaction %d", rec.ReactionID)
+	}
+	return nil
+}
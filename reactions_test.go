@@ -0,0 +1,31 @@
+package spreche
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGHReactionContent(t *testing.T) {
+	cases := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{{
+		in: "+1", want: "+1", wantOK: true,
+	}, {
+		in: "+1::skin-tone-3", want: "+1", wantOK: true,
+	}, {
+		in: "tada", want: "hooray", wantOK: true,
+	}, {
+		in: "thinking_face", wantOK: false,
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got, ok := ghReactionContent(tc.in)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("got %s, %v; want %s, %v", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
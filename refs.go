@@ -0,0 +1,155 @@
+package spreche
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// GitHub turns references like #123, owner/repo#45, GH-12, and commit SHAs into links.
+// The same is done here for GitHub markdown rendered in Slack.
+
+// ghRefs resolves GitHub references in the context of a single repo.
+// A nil *ghRefs resolves nothing.
+type ghRefs struct {
+	htmlBase    string // e.g. https://github.com, with no trailing slash
+	owner, repo string
+}
+
+// newGHRefs produces a ghRefs for the given repo
+// on the GitHub server with the given API URL.
+func newGHRefs(apiURL, owner, repo string) (*ghRefs, error) {
+	htmlBase, err := ghHTMLBase(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	return &ghRefs{htmlBase: htmlBase, owner: owner, repo: repo}, nil
+}
+
+// ghHTMLBase gives the base HTML URL for the GitHub server with the given API URL.
+// It is the inverse of ghAPIURLs.
+func ghHTMLBase(apiURL string) (string, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing URL %s", apiURL)
+	}
+	if strings.EqualFold(u.Host, "api.github.com") {
+		return "https://github.com", nil
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// ghRefRegex matches a GitHub reference.
+// The first subexpression is whatever precedes the reference
+// (which must not be something that makes it part of a longer word, path, or URL).
+// The rest are:
+// the owner and repo of a cross-repo issue reference (both empty for a same-repo one);
+// the number of an issue reference;
+// the number of a GH- reference;
+// and a commit SHA.
+var ghRefRegex = regexp.MustCompile(`(^|[^\w/#@.-])(?:(?:([A-Za-z0-9][A-Za-z0-9-]*)/([\w.-]+))?#(\d+)|GH-(\d+)|([0-9a-f]{7,40}))\b`)
+
+var (
+	hexLetterRegex = regexp.MustCompile(`[a-f]`)
+	hexDigitRegex  = regexp.MustCompile(`[0-9]`)
+)
+
+// link gives the URL and link text for the ghRefRegex match m in text,
+// or "", "" if it is not a reference after all.
+func (r *ghRefs) link(text string, m []int) (linkURL, linkText string) {
+	sub := func(i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+		return text[m[2*i]:m[2*i+1]]
+	}
+
+	var (
+		owner, repo = r.owner, r.repo
+		num         = sub(4)
+	)
+	switch {
+	case num != "":
+		if sub(2) != "" {
+			owner, repo = sub(2), sub(3)
+		}
+		return fmt.Sprintf("%s/%s/%s/issues/%s", r.htmlBase, owner, repo, num), text[m[3]:m[1]]
+
+	case sub(5) != "":
+		return fmt.Sprintf("%s/%s/%s/issues/%s", r.htmlBase, owner, repo, sub(5)), text[m[3]:m[1]]
+
+	default:
+		// Lest every hex-looking word or number become a link,
+		// a SHA must have both a letter and a digit.
+		sha := sub(6)
+		if !hexLetterRegex.MatchString(sha) || !hexDigitRegex.MatchString(sha) {
+			return "", ""
+		}
+		return fmt.Sprintf("%s/%s/%s/commit/%s", r.htmlBase, owner, repo, sha), sha[:7]
+	}
+}
+
+// ghRefsToSlack splits the text elements in a rich-text element
+// around any GitHub references,
+// replacing those with link elements.
+// Code is left alone.
+func ghRefsToSlack(elem slack.RichTextElement, refs *ghRefs) {
+	if refs == nil {
+		return
+	}
+
+	switch elem := elem.(type) {
+	case *slack.RichTextSection:
+		var secElems []slack.RichTextSectionElement
+		for _, secElem := range elem.Elements {
+			t, ok := secElem.(*slack.RichTextSectionTextElement)
+			if !ok || (t.Style != nil && t.Style.Code) {
+				secElems = append(secElems, secElem)
+				continue
+			}
+			secElems = append(secElems, splitGHRefs(t, refs)...)
+		}
+		elem.Elements = secElems
+
+	case *rtList:
+		for _, sub := range elem.Elements {
+			ghRefsToSlack(sub, refs)
+		}
+
+	case *rtQuote:
+		for _, sub := range elem.Elements {
+			ghRefsToSlack(sub, refs)
+		}
+	}
+}
+
+func splitGHRefs(t *slack.RichTextSectionTextElement, refs *ghRefs) []slack.RichTextSectionElement {
+	var (
+		result []slack.RichTextSectionElement
+		text   = t.Text
+		pos    int
+	)
+	for _, m := range ghRefRegex.FindAllStringSubmatchIndex(text, -1) {
+		linkURL, linkText := refs.link(text, m)
+		if linkURL == "" {
+			continue
+		}
+		start := m[3] // after the preceding character
+		if start > pos {
+			result = append(result, slack.NewRichTextSectionTextElement(text[pos:start], t.Style))
+		}
+		result = append(result, slack.NewRichTextSectionLinkElement(linkURL, linkText, t.Style))
+		pos = m[1]
+	}
+	if pos == 0 {
+		return []slack.RichTextSectionElement{t}
+	}
+	if pos < len(text) {
+		result = append(result, slack.NewRichTextSectionTextElement(text[pos:], t.Style))
+	}
+	return result
+}
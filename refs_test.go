@@ -0,0 +1,29 @@
+package spreche
+
+import (
+	"fmt"
+	"testing"
+)
+
+// testGHRefs is the ghRefs used by the golden tests.
+var testGHRefs = &ghRefs{htmlBase: "https://ghe.example.com", owner: "bobg", repo: "spreche"}
+
+func TestGHHTMLBase(t *testing.T) {
+	cases := []struct{ in, want string }{{
+		in: "https://api.github.com/", want: "https://github.com",
+	}, {
+		in: "https://ghe.example.com/api/v3/", want: "https://ghe.example.com",
+	}}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case_%02d", i+1), func(t *testing.T) {
+			got, err := ghHTMLBase(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
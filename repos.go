@@ -0,0 +1,104 @@
+package spreche
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+)
+
+// repoTransferChanges is the "changes" section of a "transferred" repository webhook,
+// which go-github does not parse.
+type repoTransferChanges struct {
+	Changes struct {
+		Owner struct {
+			From struct {
+				User         *github.User         `json:"user"`
+				Organization *github.Organization `json:"organization"`
+			} `json:"from"`
+		} `json:"owner"`
+	} `json:"changes"`
+}
+
+// OnRepository handles a repository webhook.
+// When a repo is renamed or transferred,
+// the channels for its PRs are updated to the new owner and name,
+// and so is the tenant's record of the repo's URL.
+//
+// Unlike other webhooks,
+// this one must find its tenant via the repo's old URL,
+// so it is not dispatched through dispatchGHEvent.
+func (s *Service) OnRepository(ctx context.Context, deliveryID string, ev *github.RepositoryEvent, payload []byte) error {
+	var (
+		newOwner = ev.Repo.GetOwner().GetLogin()
+		newName  = ev.Repo.GetName()
+		oldOwner = newOwner
+		oldName  = newName
+	)
+
+	switch ev.GetAction() {
+	case "renamed":
+		oldName = ev.GetChanges().GetRepo().GetName().GetFrom()
+
+	case "transferred":
+		var changes repoTransferChanges
+		if err := json.Unmarshal(payload, &changes); err != nil {
+			return errors.Wrap(err, "parsing repository transfer changes")
+		}
+		from := changes.Changes.Owner.From
+		if from.Organization != nil {
+			oldOwner = from.Organization.GetLogin()
+		} else {
+			oldOwner = from.User.GetLogin()
+		}
+
+	default:
+		return nil
+	}
+
+	if oldOwner == "" || oldName == "" || (oldOwner == newOwner && oldName == newName) {
+		return nil
+	}
+
+	newURL := ev.Repo.GetHTMLURL()
+	u, err := url.Parse(newURL)
+	if err != nil {
+		return errors.Wrapf(err, "parsing URL %s", newURL)
+	}
+	u.Path = path.Join(path.Dir(path.Dir(u.Path)), oldOwner, oldName)
+	oldURL := u.String()
+
+	return s.Tenants.WithTenant(ctx, 0, oldURL, "", func(ctx context.Context, tenant *Tenant) error {
+		debugf("In OnRepository, tenant ID %d: %s/%s is now %s/%s", tenant.TenantID, oldOwner, oldName, newOwner, newName)
+
+		return s.once(ctx, tenant.TenantID, deliveryID, func() error {
+			sc := tenant.SlackClient()
+
+			// Rename the Slack channels first,
+			// so that a retry after a failure here can still find the channels under the old name.
+			err := s.Channels.ForeachByRepo(ctx, tenant.TenantID, oldOwner, oldName, func(channel *Channel) error {
+				chname := ChannelName(ev.Repo, channel.PR)
+				_, err := sc.RenameConversationContext(ctx, channel.ChannelID, chname)
+				if isSlackError(err, "is_archived", "name_taken") {
+					debugf("Not renaming channel %s to %s: %s", channel.ChannelID, chname, err)
+					return nil
+				}
+				return errors.Wrapf(err, "renaming channel %s to %s", channel.ChannelID, chname)
+			})
+			if err != nil {
+				return err
+			}
+
+			err = s.Channels.RenameRepo(ctx, tenant.TenantID, oldOwner, oldName, newOwner, newName)
+			if err != nil {
+				return errors.Wrap(err, "updating channel records")
+			}
+
+			err = s.Tenants.RenameGHURL(ctx, tenant.TenantID, oldURL, newURL)
+			return errors.Wrap(err, "updating tenant repo URL")
+		})
+	})
+}
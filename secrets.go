@@ -0,0 +1,67 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bobg/mid"
+	"github.com/pkg/errors"
+)
+
+// SecretStore is a persistent store for the secrets used to verify incoming webhooks and events.
+// A tenant may have several active secrets of each kind,
+// so that a secret can be rotated:
+// add the new one, switch the GitHub App or Slack app over to it, then retire the old one.
+type SecretStore interface {
+	// Add adds a secret.
+	// On a successful return, the SecretID field of the object is populated with the new ID.
+	Add(context.Context, *Secret) error
+
+	// Retire removes a tenant's secret.
+	Retire(ctx context.Context, tenantID, secretID int64) error
+
+	// Foreach calls f for each of a tenant's secrets of the given kind,
+	// newest first.
+	// An empty kind means all kinds.
+	Foreach(ctx context.Context, tenantID int64, kind string, f func(*Secret) error) error
+}
+
+type Secret struct {
+	SecretID  int64     `json:"secret_id"`
+	TenantID  int64     `json:"tenant_id"`
+	Kind      string    `json:"kind"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Secret kinds.
+const (
+	SecretGHWebhook    = "github_webhook"
+	SecretSlackSigning = "slack_signing"
+)
+
+// tenantSecrets gets a tenant's secrets of the given kind.
+// If it has none, the result is the service-wide fallback secret
+// when s.FallbackSecrets is set,
+// and otherwise an Unauthorized error.
+func (s *Service) tenantSecrets(ctx context.Context, tenantID int64, kind, fallback string) ([]string, error) {
+	var result []string
+	err := s.Secrets.Foreach(ctx, tenantID, kind, func(secret *Secret) error {
+		result = append(result, secret.Secret)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting %s secrets for tenant %d", kind, tenantID)
+	}
+	if len(result) == 0 {
+		if !s.FallbackSecrets || fallback == "" {
+			return nil, mid.CodeErr{C: http.StatusUnauthorized, Err: fmt.Errorf("tenant %d has no %s secrets", tenantID, kind)}
+		}
+		log.Printf("Tenant %d has no %s secrets, using the global one", tenantID, kind)
+		result = []string{fallback}
+	}
+	return result, nil
+}
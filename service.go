@@ -3,6 +3,7 @@ package spreche
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/bradleyfalzon/ghinstallation/v2"
 	"github.com/google/go-github/v45/github"
@@ -14,18 +15,45 @@ type Service struct {
 	GHSecret           string
 	SlackSigningSecret string
 
-	Channels ChannelStore
-	Comments CommentStore
-	Tenants  TenantStore
-	Users    UserStore
+	// FallbackSecrets, if true,
+	// lets a tenant with no GitHub webhook or Slack signing secrets of its own (see SecretStore)
+	// use GHSecret or SlackSigningSecret.
+	// Otherwise requests for such a tenant are rejected.
+	FallbackSecrets bool
+
+	// GHAppID and GHPrivKey identify the GitHub App
+	// for tenants created from installation webhooks.
+	GHAppID   int64
+	GHPrivKey []byte
+
+	// ArchiveDelay is how long after a PR is closed or merged to archive its channel.
+	// Zero means never.
+	ArchiveDelay time.Duration
+
+	// Uploads re-hosts files shared in Slack for embedding in GitHub comments.
+	// If it is nil, such files are only linked to.
+	Uploads Uploader
+
+	BotRules   BotRuleStore
+	Channels   ChannelStore
+	Checks     CheckStore
+	Comments   CommentStore
+	Deliveries DeliveryStore
+	Groups     GroupStore
+	Jobs       JobStore
+	Reactions  ReactionStore
+	Secrets    SecretStore
+	Tenants    TenantStore
+	Users      UserStore
+
+	images imageCache
+	bots   botIdentities
 }
 
 var ErrNotFound = errors.New("not found")
 
-const ghAppID = 207677 // https://github.com/settings/apps/spreche
-
 func (t *Tenant) GHClient() (*github.Client, error) {
-	itr, err := ghinstallation.New(http.DefaultTransport, ghAppID, t.GHInstallationID, t.GHPrivKey)
+	itr, err := ghinstallation.New(http.DefaultTransport, t.GHAppID, t.GHInstallationID, t.GHPrivKey)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating transport for GitHub client")
 	}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/bobg/mid"
@@ -21,14 +22,80 @@ type eventBlocks struct {
 	} `json:"event"`
 }
 
+// OnSlackEvent verifies an incoming Slack event.
+// URL-verification requests are answered immediately.
+// Other events are queued for processing.
 func (s *Service) OnSlackEvent(w http.ResponseWriter, req *http.Request) error {
 	ctx := req.Context()
 
+	body, err := s.verifySlackRequest(req)
+	if err != nil {
+		return err
+	}
+	ev, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		return errors.Wrap(err, "parsing request body")
+	}
+	switch ev.Type {
+	case slackevents.URLVerification:
+		return s.OnURLVerification(w, ev)
+
+	case slackevents.CallbackEvent:
+		var eventID string
+		if cbEvent, ok := ev.Data.(*slackevents.EventsAPICallbackEvent); ok {
+			eventID = cbEvent.EventID
+		}
+		return s.enqueue(ctx, JobSlack, "", eventID, body)
+	}
+
+	// Ignore other event types. (xxx log them?)
+	return nil
+}
+
+// verifySlackRequest reads the body of an incoming Slack request
+// and checks its signature.
+// The tenant is found from the team ID in the body,
+// and the signature must match one of that tenant's signing secrets
+// (see SecretStore and Service.FallbackSecrets).
+// If there is no team ID (e.g. in a URL-verification request),
+// or no active tenant for it (e.g. one whose installation is still pending),
+// s.SlackSigningSecret is used.
+// A request that cannot be verified gets an Unauthorized error.
+func (s *Service) verifySlackRequest(req *http.Request) ([]byte, error) {
+	ctx := req.Context()
+
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
-		return errors.Wrap(err, "reading request body")
+		return nil, errors.Wrap(err, "reading request body")
 	}
-	v, err := slack.NewSecretsVerifier(req.Header, s.SlackSigningSecret)
+
+	secrets := []string{s.SlackSigningSecret}
+	if teamID := slackRequestTeamID(req.Header.Get("Content-Type"), body); teamID != "" {
+		err = s.Tenants.WithTenant(ctx, 0, "", teamID, func(ctx context.Context, tenant *Tenant) error {
+			secrets, err = s.tenantSecrets(ctx, tenant.TenantID, SecretSlackSigning, s.SlackSigningSecret)
+			return err
+		})
+		if errors.Is(err, ErrNotFound) {
+			debugf("No active tenant for team %s", teamID)
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "finding tenant for team %s", teamID)
+		}
+	}
+
+	err = fmt.Errorf("no signing secret")
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		if err = verifySlackSignature(req.Header, body, secret); err == nil {
+			return body, nil
+		}
+	}
+	return nil, mid.CodeErr{C: http.StatusUnauthorized, Err: err}
+}
+
+func verifySlackSignature(header http.Header, body []byte, secret string) error {
+	v, err := slack.NewSecretsVerifier(header, secret)
 	if err != nil {
 		return errors.Wrap(err, "creating request verifier")
 	}
@@ -36,23 +103,53 @@ func (s *Service) OnSlackEvent(w http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return errors.Wrap(err, "writing request body to verifier")
 	}
-	if err = v.Ensure(); err != nil {
-		return errors.Wrap(err, "verifying request signature")
+	return errors.Wrap(v.Ensure(), "verifying request signature")
+}
+
+// slackRequestTeamID extracts the team ID from an unverified Slack request body:
+// an Events API callback (JSON)
+// or an interaction (a form-encoded JSON payload).
+// It returns "" if there is none.
+func slackRequestTeamID(contentType string, body []byte) string {
+	if contentType == "application/x-www-form-urlencoded" {
+		vals, err := url.ParseQuery(string(body))
+		if err != nil {
+			return ""
+		}
+		body = []byte(vals.Get("payload"))
+	}
+	var p struct {
+		TeamID string `json:"team_id"`
+		Team   struct {
+			ID string `json:"id"`
+		} `json:"team"`
 	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return ""
+	}
+	if p.TeamID != "" {
+		return p.TeamID
+	}
+	return p.Team.ID
+}
+
+func (s *Service) handleSlackEvent(ctx context.Context, eventID string, body []byte) error {
 	ev, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
 	if err != nil {
 		return errors.Wrap(err, "parsing request body")
 	}
-	switch ev.Type {
-	case slackevents.URLVerification:
-		return s.OnURLVerification(w, ev)
+	if ev.Type != slackevents.CallbackEvent {
+		return nil
+	}
 
-	case slackevents.CallbackEvent:
-		teamID := ev.TeamID
+	teamID := ev.TeamID
 
-		return s.Tenants.WithTenant(ctx, 0, "", teamID, func(ctx context.Context, tenant *Tenant) error {
-			debugf("In OnSlackEvent, tenant ID %d", tenant.TenantID)
+	var found bool
+	err = s.Tenants.WithTenant(ctx, 0, "", teamID, func(ctx context.Context, tenant *Tenant) error {
+		found = true
+		debugf("In handleSlackEvent, tenant ID %d", tenant.TenantID)
 
+		return s.once(ctx, tenant.TenantID, eventID, func() error {
 			gh, err := tenant.GHClient()
 			if err != nil {
 				return errors.Wrap(err, "getting GitHub client")
@@ -62,31 +159,96 @@ func (s *Service) OnSlackEvent(w http.ResponseWriter, req *http.Request) error {
 			case *slackevents.MessageEvent:
 				var evBlocks struct {
 					Event struct {
-						Blocks json.RawMessage `json:"blocks"`
+						Blocks  json.RawMessage `json:"blocks"`
+						Message struct {
+							Blocks json.RawMessage `json:"blocks"`
+						} `json:"message"`
 					} `json:"event"`
 				}
 				var blocks []slack.Block
 				if err = json.Unmarshal(body, &evBlocks); err == nil { // sic
+					rawBlocks := evBlocks.Event.Blocks
+					if ev.SubType == "message_changed" {
+						// The blocks of the edited message.
+						rawBlocks = evBlocks.Event.Message.Blocks
+					}
 					var b slack.Blocks
-					if err = json.Unmarshal(evBlocks.Event.Blocks, &b); err == nil { // sic
+					if err = json.Unmarshal(rawBlocks, &b); err == nil { // sic
 						blocks = b.BlockSet
 					}
 				}
 				return s.OnMessage(ctx, teamID, gh, ev, blocks)
 
 			case *slackevents.ReactionAddedEvent:
-				return s.OnReactionAdded(ctx, gh, ev)
+				return s.OnReactionAdded(ctx, tenant, gh, ev)
 
 			case *slackevents.ReactionRemovedEvent:
-				return s.OnReactionRemoved(ctx, gh, ev)
+				return s.OnReactionRemoved(ctx, tenant, gh, ev)
 			}
 
-			return fmt.Errorf("unknown data type %T for CallbackEvent", ev.Data)
+			debugf("Ignoring %s event", ev.InnerEvent.Type)
+			return nil
 		})
+	})
+	if !found && errors.Is(err, ErrNotFound) {
+		// E.g. an event for a team whose tenant is pending or disabled
+		// (see verifySlackRequest).
+		debugf("Ignoring event for team %s, which has no active tenant", teamID)
+		return nil
 	}
+	return err
+}
 
-	// Ignore other event types. (xxx log them?)
-	return nil
+// OnSlackInteraction verifies an incoming Slack interaction,
+// such as a click on a message button,
+// and queues it for processing.
+func (s *Service) OnSlackInteraction(w http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	body, err := s.verifySlackRequest(req)
+	if err != nil {
+		return err
+	}
+	vals, err := url.ParseQuery(string(body))
+	if err != nil {
+		return errors.Wrap(err, "parsing request body")
+	}
+	payload := vals.Get("payload")
+
+	var cb slack.InteractionCallback
+	if err = json.Unmarshal([]byte(payload), &cb); err != nil {
+		return errors.Wrap(err, "parsing interaction payload")
+	}
+	if cb.Type != slack.InteractionTypeBlockActions {
+		// Ignore other interaction types.
+		return nil
+	}
+	return s.enqueue(ctx, JobSlackAction, string(cb.Type), cb.TriggerID, []byte(payload))
+}
+
+func (s *Service) handleSlackAction(ctx context.Context, triggerID string, payload []byte) error {
+	var cb slack.InteractionCallback
+	if err := json.Unmarshal(payload, &cb); err != nil {
+		return errors.Wrap(err, "parsing interaction payload")
+	}
+
+	return s.Tenants.WithTenant(ctx, 0, "", cb.Team.ID, func(ctx context.Context, tenant *Tenant) error {
+		debugf("In handleSlackAction, tenant ID %d", tenant.TenantID)
+
+		return s.once(ctx, tenant.TenantID, triggerID, func() error {
+			for _, action := range cb.ActionCallback.BlockActions {
+				switch action.ActionID {
+				case suggestionActionID:
+					if err := s.commitSuggestion(ctx, tenant, &cb, action.Value); err != nil {
+						return errors.Wrap(err, "committing suggestion")
+					}
+				default:
+					debugf("Ignoring unknown Slack action %s", action.ActionID)
+				}
+			}
+			return nil
+		})
+	})
 }
 
 func (s *Service) OnURLVerification(w http.ResponseWriter, ev slackevents.EventsAPIEvent) error {
@@ -97,77 +259,105 @@ func (s *Service) OnURLVerification(w http.ResponseWriter, ev slackevents.Events
 	return mid.RespondJSON(w, slackevents.ChallengeResponse{Challenge: v.Challenge})
 }
 
+// OnMessage mirrors a message in a PR channel as a GitHub comment.
+// An edited message updates its comment,
+// and a deleted message deletes its comment or replaces it with a tombstone,
+// according to the tenant's DeletePolicy.
+// Files shared with a message are embedded in its comment
+// (see Service.filesToGH).
 func (s *Service) OnMessage(ctx context.Context, teamID string, gh *github.Client, ev *slackevents.MessageEvent, blocks []slack.Block) error {
 	if ev.ChannelType != "channel" {
 		return nil
 	}
-	if ev.BotID != "" {
-		return nil
-	}
+
+	// For an edited or deleted message,
+	// msg is the message in question
+	// (new or old, respectively).
+	msg := ev
 	switch ev.SubType {
 	case "channel_join", "channel_topic":
 		return nil
 	case "message_changed":
-		if ev.Message != nil && ev.Message.BotID != "" {
+		if ev.Message == nil {
 			return nil
 		}
+		if ev.PreviousMessage != nil && ev.PreviousMessage.Text == ev.Message.Text {
+			// Something other than the text changed,
+			// such as an unfurled link.
+			return nil
+		}
+		msg = ev.Message
+	case "message_deleted":
+		if ev.PreviousMessage == nil {
+			return nil
+		}
+		msg = ev.PreviousMessage
+	}
+
+	// Most bot messages in PR channels are this app's own.
+	// Once its bot ID is known they are dropped without looking anything up.
+	if ownID, ok := s.bots.get(teamID); ok && msg.BotID != "" && msg.BotID == ownID {
+		return nil
 	}
 
 	return s.Tenants.WithTenant(ctx, 0, "", teamID, func(ctx context.Context, tenant *Tenant) error {
-		sc := tenant.SlackClient()
+		own, err := s.isOwnSlackBot(ctx, tenant, teamID, msg.BotID)
+		if err != nil || own {
+			return err
+		}
 
 		channel, err := s.Channels.ByChannelID(ctx, tenant.TenantID, ev.Channel)
 		if err != nil {
 			return errors.Wrapf(err, "getting info for channelID %s", ev.Channel)
 		}
 
-		user, err := s.Users.BySlackID(ctx, tenant.TenantID, ev.User)
-		if errors.Is(err, ErrNotFound) {
-			debugf("Found no GitHub user for slack ID %s", ev.User)
-			user = nil
-		} else if err != nil {
-			return errors.Wrapf(err, "getting info for userID %s", ev.User)
-		} else {
-			debugf("Found GitHub user %s for slack ID %s", user.GHLogin, ev.User)
+		skip, err := s.skipSlackBot(ctx, tenant, channel.Owner+"/"+channel.Repo, msg.BotID, msg.Username, msg.Text)
+		if err != nil || skip {
+			return err
 		}
 
-		slackUser, err := sc.GetUserInfoContext(ctx, ev.User)
-		if err != nil {
-			return errors.Wrapf(err, "getting Slack info for user %s", ev.User)
+		switch ev.SubType {
+		case "message_changed":
+			return s.onMessageChanged(ctx, tenant, gh, channel, msg, blocks)
+		case "message_deleted":
+			return s.onMessageDeleted(ctx, tenant, gh, channel, msg)
 		}
 
-		team, err := sc.GetTeamInfoContext(ctx)
+		body, user, err := s.slackMessageToGH(ctx, tenant, channel, msg, blocks, "")
 		if err != nil {
-			return errors.Wrap(err, "getting team info")
+			return err
 		}
 
-		// Reverse-engineered Slack-comment link.
-		eventID := ev.EventTimeStamp.String()
-		eventID = strings.Replace(eventID, ".", "", -1)
-		commentURL := fmt.Sprintf("https://%s.slack.com/archives/%s/p%s", team.Domain, ev.Channel, eventID)
 		if ev.ThreadTimeStamp != "" {
-			commentURL += fmt.Sprintf("?thread_ts=%s&cid=%s", ev.ThreadTimeStamp, ev.Channel)
+			var parent *Comment
+			if channel.PRBodyTS != "" && ev.ThreadTimeStamp == channel.PRBodyTS {
+				parent = &Comment{
+					ChannelID:       channel.ChannelID,
+					ThreadTimestamp: ev.ThreadTimeStamp,
+					Kind:            CommentKindPRBody,
+				}
+			} else {
+				parent, err = s.Comments.ByThreadTimestamp(ctx, tenant.TenantID, channel.ChannelID, ev.ThreadTimeStamp)
+				if err != nil {
+					return errors.Wrapf(err, "getting latest comment in thread %s", ev.ThreadTimeStamp)
+				}
+			}
+			replyID, kind, err := replyOnGH(ctx, gh, channel, parent, body)
+			if err != nil {
+				return err
+			}
+			// Record the reply under its own timestamp,
+			// for finding it again if the message is edited or deleted.
+			return s.Comments.Add(ctx, tenant.TenantID, channel.ChannelID, ev.TimeStamp, replyID, kind)
 		}
 
-		body := textOrBlocksToGH(commentURL, slackUser.Name, ev.Text, blocks)
+		debugf("Creating new top-level comment (%s/%s/%d)", channel.Owner, channel.Repo, channel.PR)
 
 		var ghuser *github.User
 		if user != nil {
 			ghuser = &github.User{Login: &user.GHLogin}
 		}
 
-		if ev.ThreadTimeStamp != "" {
-			comment, err := s.Comments.ByThreadTimestamp(ctx, tenant.TenantID, channel.ChannelID, ev.ThreadTimeStamp)
-			if err != nil {
-				return errors.Wrapf(err, "getting latest comment in thread %s", ev.ThreadTimeStamp)
-			}
-			debugf("Creating comment (%s/%s/%d) in reply to %d", channel.Owner, channel.Repo, channel.PR, comment.CommentID)
-			_, _, err = gh.PullRequests.CreateCommentInReplyTo(ctx, channel.Owner, channel.Repo, channel.PR, body, comment.CommentID)
-			return errors.Wrap(err, "creating comment")
-		}
-
-		debugf("Creating new top-level comment (%s/%s/%d)", channel.Owner, channel.Repo, channel.PR)
-
 		issueComment, _, err := gh.Issues.CreateComment(ctx, channel.Owner, channel.Repo, channel.PR, &github.IssueComment{
 			Body: &body,
 			User: ghuser,
@@ -176,16 +366,248 @@ func (s *Service) OnMessage(ctx context.Context, teamID string, gh *github.Clien
 			return errors.Wrap(err, "creating comment")
 		}
 
-		return s.Comments.Add(ctx, tenant.TenantID, channel.ChannelID, ev.TimeStamp, *issueComment.ID)
+		return s.Comments.Add(ctx, tenant.TenantID, channel.ChannelID, ev.TimeStamp, *issueComment.ID, CommentKindIssueComment)
 	})
 }
 
-func (s *Service) OnReactionAdded(ctx context.Context, gh *github.Client, ev *slackevents.ReactionAddedEvent) error {
-	// xxx
-	return nil
+// replyOnGH posts body on GitHub as a reply to the parent comment,
+// returning the ID and kind of the new comment.
+// Only a review comment can be replied to directly.
+// Any other reply is a new issue comment quoting and linking the parent.
+func replyOnGH(ctx context.Context, gh *github.Client, channel *Channel, parent *Comment, body string) (replyID int64, replyKind string, err error) {
+	err = tryCommentKinds(parent, func(kind string) error {
+		var err error
+		replyID, replyKind, err = replyOnGHAs(ctx, gh, channel, parent, kind, body)
+		return err
+	})
+	return replyID, replyKind, err
 }
 
-func (s *Service) OnReactionRemoved(ctx context.Context, gh *github.Client, ev *slackevents.ReactionRemovedEvent) error {
-	// xxx
-	return nil
+// replyOnGHAs is replyOnGH for a parent comment of the given kind.
+func replyOnGHAs(ctx context.Context, gh *github.Client, channel *Channel, parent *Comment, kind, body string) (int64, string, error) {
+	var (
+		parentURL, parentAuthor, parentBody string
+		err                                 error
+	)
+
+	switch kind {
+	case CommentKindReviewComment:
+		debugf("Creating comment (%s/%s/%d) in reply to %d", channel.Owner, channel.Repo, channel.PR, parent.CommentID)
+		reply, _, err := gh.PullRequests.CreateCommentInReplyTo(ctx, channel.Owner, channel.Repo, channel.PR, body, parent.CommentID)
+		if err != nil {
+			return 0, "", errors.Wrap(err, "creating comment")
+		}
+		return reply.GetID(), CommentKindReviewComment, nil
+
+	case CommentKindIssueComment:
+		var c *github.IssueComment
+		c, _, err = gh.Issues.GetComment(ctx, channel.Owner, channel.Repo, parent.CommentID)
+		parentURL, parentAuthor, parentBody = c.GetHTMLURL(), c.GetUser().GetLogin(), c.GetBody()
+
+	case CommentKindReview:
+		var r *github.PullRequestReview
+		r, _, err = gh.PullRequests.GetReview(ctx, channel.Owner, channel.Repo, channel.PR, parent.CommentID)
+		parentURL, parentAuthor, parentBody = r.GetHTMLURL(), r.GetUser().GetLogin(), r.GetBody()
+
+	case CommentKindPRBody:
+		var pr *github.PullRequest
+		pr, _, err = gh.PullRequests.Get(ctx, channel.Owner, channel.Repo, channel.PR)
+		parentURL, parentAuthor, parentBody = pr.GetHTMLURL(), pr.GetUser().GetLogin(), pr.GetBody()
+
+	default:
+		return 0, "", fmt.Errorf("unknown comment kind %s", kind)
+	}
+	if err != nil {
+		return 0, "", errors.Wrapf(err, "getting %s %d replied to", kind, parent.CommentID)
+	}
+
+	debugf("Creating comment (%s/%s/%d) quoting %s %d", channel.Owner, channel.Repo, channel.PR, kind, parent.CommentID)
+	body = ghQuoteReply(parentURL, parentAuthor, parentBody, body)
+	c, _, err := gh.Issues.CreateComment(ctx, channel.Owner, channel.Repo, channel.PR, &github.IssueComment{Body: &body})
+	if err != nil {
+		return 0, "", errors.Wrap(err, "creating comment")
+	}
+	return c.GetID(), CommentKindIssueComment, nil
+}
+
+// maxQuoteLines is the most lines of a parent comment quoted by ghQuoteReply.
+const maxQuoteLines = 5
+
+// ghQuoteReply prefixes body with a link to the comment it replies to
+// and a quote of (the start of) that comment.
+func ghQuoteReply(parentURL, parentAuthor, parentBody, body string) string {
+	buf := new(strings.Builder)
+	fmt.Fprintf(buf, "_In reply to [this comment](%s)", parentURL)
+	if parentAuthor != "" {
+		fmt.Fprintf(buf, " by %s", parentAuthor)
+	}
+	fmt.Fprint(buf, "_\n")
+
+	parentBody = strings.TrimSpace(strings.ReplaceAll(parentBody, "\r\n", "\n"))
+	if parentBody != "" {
+		lines := strings.Split(parentBody, "\n")
+		if len(lines) > maxQuoteLines {
+			lines = append(lines[:maxQuoteLines], "…")
+		}
+		fmt.Fprint(buf, "\n")
+		for _, line := range lines {
+			fmt.Fprintln(buf, strings.TrimRight("> "+line, " "))
+		}
+	}
+
+	fmt.Fprint(buf, "\n", body)
+	return buf.String()
+}
+
+// onMessageChanged updates the GitHub comment for an edited Slack message.
+func (s *Service) onMessageChanged(ctx context.Context, tenant *Tenant, gh *github.Client, channel *Channel, msg *slackevents.MessageEvent, blocks []slack.Block) error {
+	comment, err := s.Comments.ByThreadTimestamp(ctx, tenant.TenantID, channel.ChannelID, msg.TimeStamp)
+	if errors.Is(err, ErrNotFound) {
+		debugf("No comment for edited message %s in channel %s", msg.TimeStamp, channel.ChannelID)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting comment for message %s", msg.TimeStamp)
+	}
+
+	body, _, err := s.slackMessageToGH(ctx, tenant, channel, msg, blocks, "")
+	if err != nil {
+		return err
+	}
+
+	debugf("Editing comment %d (%s/%s/%d)", comment.CommentID, channel.Owner, channel.Repo, channel.PR)
+	return editGHComment(ctx, gh, channel, comment, body)
+}
+
+// onMessageDeleted deletes or tombstones the GitHub comment for a deleted Slack message.
+func (s *Service) onMessageDeleted(ctx context.Context, tenant *Tenant, gh *github.Client, channel *Channel, msg *slackevents.MessageEvent) error {
+	comment, err := s.Comments.ByThreadTimestamp(ctx, tenant.TenantID, channel.ChannelID, msg.TimeStamp)
+	if errors.Is(err, ErrNotFound) {
+		debugf("No comment for deleted message %s in channel %s", msg.TimeStamp, channel.ChannelID)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting comment for message %s", msg.TimeStamp)
+	}
+
+	if tenant.DeletePolicy == DeletePolicyDelete {
+		debugf("Deleting comment %d (%s/%s/%d)", comment.CommentID, channel.Owner, channel.Repo, channel.PR)
+
+		err = tryCommentKinds(comment, func(kind string) error {
+			var err error
+			switch kind {
+			case CommentKindIssueComment:
+				_, err = gh.Issues.DeleteComment(ctx, channel.Owner, channel.Repo, comment.CommentID)
+			case CommentKindReviewComment:
+				_, err = gh.PullRequests.DeleteComment(ctx, channel.Owner, channel.Repo, comment.CommentID)
+			default:
+				debugf("Not deleting %s %d", kind, comment.CommentID)
+			}
+			return err
+		})
+		if isGHNotFound(err) {
+			debugf("Comment %d already deleted", comment.CommentID)
+			return nil
+		}
+		return errors.Wrapf(err, "deleting comment %d", comment.CommentID)
+	}
+
+	body, _, err := s.slackMessageToGH(ctx, tenant, channel, msg, nil, "_This comment was deleted in Slack._")
+	if err != nil {
+		return err
+	}
+
+	debugf("Tombstoning comment %d (%s/%s/%d)", comment.CommentID, channel.Owner, channel.Repo, channel.PR)
+	return editGHComment(ctx, gh, channel, comment, body)
+}
+
+// editGHComment replaces the body of an issue comment or review comment.
+func editGHComment(ctx context.Context, gh *github.Client, channel *Channel, comment *Comment, body string) error {
+	err := tryCommentKinds(comment, func(kind string) error {
+		var err error
+		switch kind {
+		case CommentKindIssueComment:
+			_, _, err = gh.Issues.EditComment(ctx, channel.Owner, channel.Repo, comment.CommentID, &github.IssueComment{Body: &body})
+		case CommentKindReviewComment:
+			_, _, err = gh.PullRequests.EditComment(ctx, channel.Owner, channel.Repo, comment.CommentID, &github.PullRequestComment{Body: &body})
+		default:
+			debugf("Not editing %s %d", kind, comment.CommentID)
+		}
+		return err
+	})
+	if isGHNotFound(err) {
+		debugf("Comment %d not found", comment.CommentID)
+		return nil
+	}
+	return errors.Wrapf(err, "editing comment %d", comment.CommentID)
+}
+
+// slackMessageToGH renders a Slack message as the body of a GitHub comment,
+// with a header linking to the message and naming its author.
+// If tombstone is non-empty,
+// it replaces the content of the message.
+// The result includes the GitHub counterpart of the message's author,
+// or nil if there is none.
+func (s *Service) slackMessageToGH(ctx context.Context, tenant *Tenant, channel *Channel, msg *slackevents.MessageEvent, blocks []slack.Block, tombstone string) (string, *User, error) {
+	sc := tenant.SlackClient()
+
+	var (
+		user     *User
+		username string
+		err      error
+	)
+	if msg.BotID != "" {
+		username = msg.Username
+		if username == "" {
+			bot, err := sc.GetBotInfoContext(ctx, msg.BotID)
+			if err != nil {
+				return "", nil, errors.Wrapf(err, "getting Slack info for bot %s", msg.BotID)
+			}
+			username = bot.Name
+		}
+	} else {
+		user, err = s.Users.BySlackID(ctx, tenant.TenantID, msg.User)
+		if errors.Is(err, ErrNotFound) {
+			debugf("Found no GitHub user for slack ID %s", msg.User)
+			user = nil
+		} else if err != nil {
+			return "", nil, errors.Wrapf(err, "getting info for userID %s", msg.User)
+		} else {
+			debugf("Found GitHub user %s for slack ID %s", user.GHLogin, msg.User)
+		}
+
+		slackUser, err := sc.GetUserInfoContext(ctx, msg.User)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "getting Slack info for user %s", msg.User)
+		}
+		username = slackUser.Name
+	}
+
+	team, err := sc.GetTeamInfoContext(ctx)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "getting team info")
+	}
+
+	// Reverse-engineered Slack-comment link.
+	msgID := strings.Replace(msg.TimeStamp, ".", "", -1)
+	commentURL := fmt.Sprintf("https://%s.slack.com/archives/%s/p%s", team.Domain, channel.ChannelID, msgID)
+	if msg.ThreadTimeStamp != "" {
+		commentURL += fmt.Sprintf("?thread_ts=%s&cid=%s", msg.ThreadTimeStamp, channel.ChannelID)
+	}
+
+	if tombstone != "" {
+		return ghCommentHeader(commentURL, username) + "\n\n" + tombstone, user, nil
+	}
+
+	users, err := s.slackUserMap(ctx, tenant, msg.Text, blocks)
+	if err != nil {
+		return "", nil, err
+	}
+
+	files, err := s.filesToGH(ctx, tenant, msg.Files)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return textOrBlocksToGH(commentURL, username, msg.Text, blocks, users) + files, user, nil
 }
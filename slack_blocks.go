@@ -9,44 +9,50 @@ import (
 	"github.com/slack-go/slack"
 )
 
-func textOrBlocksToGH(commentURL, username, text string, blocks []slack.Block) string {
+func textOrBlocksToGH(commentURL, username, text string, blocks []slack.Block, users *userMap) string {
 	buf := new(bytes.Buffer)
-	fmt.Fprintf(buf, "_[[Comment](%s) from %s]_", commentURL, username)
+	fmt.Fprint(buf, ghCommentHeader(commentURL, username))
 	if len(blocks) == 0 {
-		fmt.Fprint(buf, "\n\n", ghEscape(text)) // xxx escaping of text
+		fmt.Fprint(buf, "\n\n", slackMentionsToGH(ghEscape(text), users)) // xxx escaping of text
 	} else {
-		blocksToGH(buf, blocks)
+		blocksToGH(buf, blocks, users)
 	}
 	return buf.String()
 }
 
-func blocksToGH(w io.Writer, blocks []slack.Block) {
+// ghCommentHeader is the first line of a GitHub comment made from a Slack message,
+// linking to the message and naming its author.
+func ghCommentHeader(commentURL, username string) string {
+	return fmt.Sprintf("_[[Comment](%s) from %s]_", commentURL, username)
+}
+
+func blocksToGH(w io.Writer, blocks []slack.Block, users *userMap) {
 	for _, block := range blocks {
 		fmt.Fprint(w, "\n\n")
-		blockToGH(w, block)
+		blockToGH(w, block, users)
 	}
 }
 
-func blockToGH(w io.Writer, block slack.Block) {
+func blockToGH(w io.Writer, block slack.Block, users *userMap) {
 	switch block := block.(type) {
 	case slack.ActionBlock:
 		fmt.Fprint(w, "[unrendered action block]")
 
 	case *slack.ContextBlock:
 		for _, elem := range block.ContextElements.Elements {
-			mixedElementToGH(w, elem)
+			mixedElementToGH(w, elem, users)
 		}
 
 	case *slack.DividerBlock:
 		fmt.Fprint(w, "---")
 
 	case *slack.FileBlock:
-		fmt.Fprint(w, "[unrendered file block]")
+		// Files are rendered from the message's file list (see Service.filesToGH).
 
 	case *slack.HeaderBlock:
 		if block.Text != nil {
 			fmt.Fprint(w, "## ")
-			textBlockObjectToGH(w, block.Text)
+			textBlockObjectToGH(w, block.Text, users)
 		}
 
 	case *slack.ImageBlock:
@@ -57,19 +63,19 @@ func blockToGH(w io.Writer, block slack.Block) {
 
 	case *slack.RichTextBlock:
 		for _, elem := range block.Elements {
-			richTextElementToGH(w, elem)
+			richTextElementToGH(w, elem, users)
 		}
 
 	case *slack.SectionBlock:
 		if len(block.Fields) > 0 {
-			sectionFieldsToGH(w, block.Fields)
+			sectionFieldsToGH(w, block.Fields, users)
 		} else {
-			textBlockObjectToGH(w, block.Text)
+			textBlockObjectToGH(w, block.Text, users)
 		}
 		// TODO: block.Accessory
 
 	case *slack.TextBlockObject:
-		textBlockObjectToGH(w, block)
+		textBlockObjectToGH(w, block, users)
 
 	default:
 		fmt.Fprintf(w, "[unknown Slack block type %T (%s)]", block, block.BlockType())
@@ -80,24 +86,24 @@ func imageToGH(w io.Writer, imageURL, altText string, title *slack.TextBlockObje
 	fmt.Fprintf(w, "![%s](%s)", ghEscape(altText), imageURL) // xxx title
 }
 
-func mixedElementToGH(w io.Writer, elem slack.MixedElement) {
+func mixedElementToGH(w io.Writer, elem slack.MixedElement, users *userMap) {
 	switch elem := elem.(type) {
 	case *slack.ImageBlockElement:
 		imageToGH(w, elem.ImageURL, elem.AltText, nil)
 
 	case *slack.TextBlockObject:
-		textBlockObjectToGH(w, elem)
+		textBlockObjectToGH(w, elem, users)
 
 	default:
 		fmt.Fprintf(w, "[unknown Slack mixed-element type %T (%s)]", elem, elem.MixedElementType())
 	}
 }
 
-func richTextElementToGH(w io.Writer, elem slack.RichTextElement) {
+func richTextElementToGH(w io.Writer, elem slack.RichTextElement, users *userMap) {
 	switch elem := elem.(type) {
 	case *slack.RichTextSection:
 		for _, ee := range elem.Elements {
-			richTextSectionElementToGH(w, ee)
+			richTextSectionElementToGH(w, ee, users)
 		}
 
 	default:
@@ -105,7 +111,7 @@ func richTextElementToGH(w io.Writer, elem slack.RichTextElement) {
 	}
 }
 
-func richTextSectionElementToGH(w io.Writer, elem slack.RichTextSectionElement) {
+func richTextSectionElementToGH(w io.Writer, elem slack.RichTextSectionElement, users *userMap) {
 	switch elem := elem.(type) {
 	case *slack.RichTextSectionBroadcastElement:
 		styledContentToGH(
@@ -150,7 +156,7 @@ func richTextSectionElementToGH(w io.Writer, elem slack.RichTextSectionElement)
 		})
 
 	case *slack.RichTextSectionUserElement:
-		styledContentToGH(w, elem.Style, func(_ bool) { fmt.Fprint(w, elem.UserID) })
+		styledContentToGH(w, elem.Style, func(_ bool) { fmt.Fprint(w, users.ghMention(elem.UserID)) })
 
 	case *slack.RichTextSectionUserGroupElement:
 		fmt.Fprint(w, elem.UsergroupID) // xxx escaping
@@ -160,18 +166,18 @@ func richTextSectionElementToGH(w io.Writer, elem slack.RichTextSectionElement)
 	}
 }
 
-func textBlockObjectToGH(w io.Writer, obj *slack.TextBlockObject) {
-	fmt.Fprint(w, ghEscape(obj.Text)) // xxx obj.Type (plain_text or mrkdwn), obj.Emoji, obj.Verbatim
+func textBlockObjectToGH(w io.Writer, obj *slack.TextBlockObject, users *userMap) {
+	fmt.Fprint(w, slackMentionsToGH(ghEscape(obj.Text), users)) // xxx obj.Type (plain_text or mrkdwn), obj.Emoji, obj.Verbatim
 }
 
-func sectionFieldsToGH(w io.Writer, objs []*slack.TextBlockObject) {
+func sectionFieldsToGH(w io.Writer, objs []*slack.TextBlockObject, users *userMap) {
 	// xxx is a header line required?
 	for i := 0; i < len(objs); i += 2 {
 		fmt.Fprint(w, "| ")
-		textBlockObjectToGH(w, objs[i])
+		textBlockObjectToGH(w, objs[i], users)
 		if i+1 < len(objs) {
 			fmt.Fprint(w, " | ")
-			textBlockObjectToGH(w, objs[i+1])
+			textBlockObjectToGH(w, objs[i+1], users)
 		}
 		fmt.Fprintln(w, " |")
 	}
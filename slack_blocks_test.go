@@ -41,7 +41,7 @@ func TestSlackBlocks(t *testing.T) {
 				t.Fatal(err)
 			}
 			buf := new(bytes.Buffer)
-			blocksToGH(buf, b.BlockSet)
+			blocksToGH(buf, b.BlockSet, testUserMap)
 
 			output := strings.TrimSuffix(input, ".input")
 			output += ".output"
@@ -0,0 +1,258 @@
+package spreche
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestMessageEditAndDelete(t *testing.T) {
+	const channelID = "C0PRCHAN"
+
+	s, api := newFakeService(t, channelID)
+
+	ctx := context.Background()
+
+	repo := &github.Repository{Owner: &github.User{Login: github.String("bobg")}, Name: github.String("spreche")}
+	if err := s.Channels.Add(ctx, 1, channelID, repo, 17, "1.000000"); err != nil {
+		t.Fatal(err)
+	}
+
+	tenant := s.Tenants.(fakeTenantStore).tenant
+	gh, err := tenant.GHClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &slackevents.MessageEvent{
+		Type:        "message",
+		User:        "U0ALICE",
+		Text:        "Looks good",
+		TimeStamp:   "2.000000",
+		Channel:     channelID,
+		ChannelType: "channel",
+	}
+	if err = s.OnMessage(ctx, "T0TEAM", gh, msg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("POST /api/v3/repos/bobg/spreche/issues/17/comments"); n != 1 {
+		t.Fatalf("got %d comment creations, want 1", n)
+	}
+
+	edited := *msg
+	edited.Text = "Looks great"
+	err = s.OnMessage(ctx, "T0TEAM", gh, &slackevents.MessageEvent{
+		Type:            "message",
+		SubType:         "message_changed",
+		Channel:         channelID,
+		ChannelType:     "channel",
+		Message:         &edited,
+		PreviousMessage: msg,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("POST /api/v3/repos/bobg/spreche/issues/17/comments"); n != 1 {
+		t.Errorf("got %d comment creations after edit, want 1", n)
+	}
+	if n := api.count("PATCH /api/v3/repos/bobg/spreche/issues/comments/"); n != 1 {
+		t.Errorf("got %d comment edits, want 1", n)
+	}
+
+	deleted := &slackevents.MessageEvent{
+		Type:            "message",
+		SubType:         "message_deleted",
+		Channel:         channelID,
+		ChannelType:     "channel",
+		PreviousMessage: &edited,
+	}
+	if err = s.OnMessage(ctx, "T0TEAM", gh, deleted, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("PATCH /api/v3/repos/bobg/spreche/issues/comments/"); n != 2 {
+		t.Errorf("got %d comment edits after tombstoning, want 2", n)
+	}
+
+	tenant.DeletePolicy = DeletePolicyDelete
+	if err = s.OnMessage(ctx, "T0TEAM", gh, deleted, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("DELETE /api/v3/repos/bobg/spreche/issues/comments/"); n != 1 {
+		t.Errorf("got %d comment deletions, want 1", n)
+	}
+}
+
+func TestThreadReplies(t *testing.T) {
+	const channelID = "C0PRCHAN"
+
+	cases := []struct {
+		name, kind string
+		wantGet    string
+		wantPost   string
+	}{{
+		name:     "review_comment",
+		kind:     CommentKindReviewComment,
+		wantPost: "POST /api/v3/repos/bobg/spreche/pulls/17/comments",
+	}, {
+		name:     "issue_comment",
+		kind:     CommentKindIssueComment,
+		wantGet:  "GET /api/v3/repos/bobg/spreche/issues/comments/500",
+		wantPost: "POST /api/v3/repos/bobg/spreche/issues/17/comments",
+	}, {
+		name:     "review",
+		kind:     CommentKindReview,
+		wantGet:  "GET /api/v3/repos/bobg/spreche/pulls/17/reviews/500",
+		wantPost: "POST /api/v3/repos/bobg/spreche/issues/17/comments",
+	}, {
+		// A reply to the status card, which has no comment record.
+		name:     "pr_body",
+		kind:     CommentKindPRBody,
+		wantGet:  "GET /api/v3/repos/bobg/spreche/pulls/17",
+		wantPost: "POST /api/v3/repos/bobg/spreche/issues/17/comments",
+	}, {
+		// A record made before kinds were recorded is tried as an issue comment first.
+		name:     "unknown",
+		kind:     "",
+		wantGet:  "GET /api/v3/repos/bobg/spreche/issues/comments/500",
+		wantPost: "POST /api/v3/repos/bobg/spreche/issues/17/comments",
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, api := newFakeService(t, channelID)
+
+			ctx := context.Background()
+
+			repo := &github.Repository{Owner: &github.User{Login: github.String("bobg")}, Name: github.String("spreche")}
+			if err := s.Channels.Add(ctx, 1, channelID, repo, 17, "1.000000"); err != nil {
+				t.Fatal(err)
+			}
+			threadTS := "2.000000"
+			if tc.kind == CommentKindPRBody {
+				threadTS = "1.000000"
+			} else if err := s.Comments.Add(ctx, 1, channelID, threadTS, 500, tc.kind); err != nil {
+				t.Fatal(err)
+			}
+
+			gh, err := s.Tenants.(fakeTenantStore).tenant.GHClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.OnMessage(ctx, "T0TEAM", gh, &slackevents.MessageEvent{
+				Type:            "message",
+				User:            "U0ALICE",
+				Text:            "Agreed",
+				TimeStamp:       "3.000000",
+				ThreadTimeStamp: threadTS,
+				Channel:         channelID,
+				ChannelType:     "channel",
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.wantGet != "" && api.count(tc.wantGet) != 1 {
+				t.Errorf("got %d requests for %s, want 1", api.count(tc.wantGet), tc.wantGet)
+			}
+			if n := api.count(tc.wantPost); n != 1 {
+				t.Errorf("got %d requests for %s, want 1", n, tc.wantPost)
+			}
+
+			reply, err := s.Comments.ByThreadTimestamp(ctx, 1, channelID, "3.000000")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantKind := CommentKindIssueComment
+			if tc.kind == CommentKindReviewComment {
+				wantKind = CommentKindReviewComment
+			}
+			if reply.Kind != wantKind {
+				t.Errorf("got reply kind %s, want %s", reply.Kind, wantKind)
+			}
+		})
+	}
+}
+
+func TestGHQuoteReply(t *testing.T) {
+	cases := []struct {
+		author, parent, want string
+	}{{
+		author: "alice",
+		parent: "Please rename this.\r\n\r\nThanks!",
+		want:   "_In reply to [this comment](https://github.com/c) by alice_\n\n> Please rename this.\n>\n> Thanks!\n\nBODY",
+	}, {
+		parent: "",
+		want:   "_In reply to [this comment](https://github.com/c)_\n\nBODY",
+	}, {
+		author: "bob",
+		parent: "1\n2\n3\n4\n5\n6\n7",
+		want:   "_In reply to [this comment](https://github.com/c) by bob_\n\n> 1\n> 2\n> 3\n> 4\n> 5\n> …\n\nBODY",
+	}}
+
+	for i, tc := range cases {
+		got := ghQuoteReply("https://github.com/c", tc.author, tc.parent, "BODY")
+		if got != tc.want {
+			t.Errorf("case %d: got %q, want %q", i+1, got, tc.want)
+		}
+	}
+}
+
+func TestSlackEventNoTenant(t *testing.T) {
+	s, api := newFakeService(t, "C0PRCHAN")
+	s.Tenants = fakeTenantStore{}
+	s.SlackSigningSecret = "global-secret"
+
+	body := []byte(`{"type":"event_callback","team_id":"T0PENDING","event_id":"Ev1","event":{"type":"message","user":"U0ALICE","text":"hi","ts":"1.000000","channel":"C0PRCHAN"}}`)
+
+	// With no active tenant for the team,
+	// the request is verified with the global secret.
+	req := signedSlackRequest(t, body, "global-secret")
+	got, err := s.verifySlackRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(body) {
+		t.Errorf("got body %s, want %s", got, body)
+	}
+
+	req = signedSlackRequest(t, body, "wrong-secret")
+	if _, err = s.verifySlackRequest(req); err == nil {
+		t.Error("got no error verifying request with the wrong secret")
+	}
+
+	// ...and the event is ignored.
+	if err = s.handleSlackEvent(context.Background(), "Ev1", body); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("POST /api/v3/repos/bobg/spreche/issues/17/comments"); n != 0 {
+		t.Errorf("got %d GitHub comments, want 0", n)
+	}
+}
+
+// signedSlackRequest makes an incoming Slack request
+// with the signature headers Slack would add.
+func signedSlackRequest(t *testing.T, body []byte, secret string) *http.Request {
+	t.Helper()
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+
+	req := httptest.NewRequest("POST", "/slack/event", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
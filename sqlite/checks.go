@@ -0,0 +1,49 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/bobg/sqlutil"
+	"github.com/pkg/errors"
+
+	"spreche"
+)
+
+type checkStore struct {
+	db *sql.DB
+}
+
+var _ spreche.CheckStore = checkStore{}
+
+func (c checkStore) BySHA(ctx context.Context, tenantID int64, channelID, sha string) (string, error) {
+	const q = `SELECT timestamp FROM checks WHERE tenant_id = $1 AND channel_id = $2 AND sha = $3`
+	var timestamp string
+	err := sqlutil.QueryRowContext(ctx, c.db, q, tenantID, channelID, sha).Scan(&timestamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = spreche.ErrNotFound
+	}
+	return timestamp, err
+}
+
+func (c checkStore) Reserve(ctx context.Context, tenantID int64, channelID, sha string) (bool, error) {
+	const q = `INSERT INTO checks (tenant_id, channel_id, sha, timestamp) VALUES ($1, $2, $3, '') ON CONFLICT DO NOTHING`
+	res, err := c.db.ExecContext(ctx, q, tenantID, channelID, sha)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, errors.Wrap(err, "counting affected rows")
+}
+
+func (c checkStore) SetTimestamp(ctx context.Context, tenantID int64, channelID, sha, timestamp string) error {
+	const q = `UPDATE checks SET timestamp = $1 WHERE tenant_id = $2 AND channel_id = $3 AND sha = $4`
+	_, err := c.db.ExecContext(ctx, q, timestamp, tenantID, channelID, sha)
+	return err
+}
+
+func (c checkStore) Unreserve(ctx context.Context, tenantID int64, channelID, sha string) error {
+	const q = `DELETE FROM checks WHERE tenant_id = $1 AND channel_id = $2 AND sha = $3 AND timestamp = ''`
+	_, err := c.db.ExecContext(ctx, q, tenantID, channelID, sha)
+	return err
+}
@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"spreche"
+)
+
+type deliveryStore struct {
+	db *sql.DB
+}
+
+var _ spreche.DeliveryStore = deliveryStore{}
+
+func (d deliveryStore) Add(ctx context.Context, tenantID int64, deliveryID string, exp time.Time) (bool, error) {
+	const q = `
+		INSERT INTO deliveries (tenant_id, delivery_id, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (tenant_id, delivery_id) DO UPDATE SET expires_at = excluded.expires_at
+			WHERE deliveries.expires_at < $4
+	`
+	res, err := d.db.ExecContext(ctx, q, tenantID, deliveryID, exp.UTC(), time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "counting affected rows")
+	}
+	return n > 0, nil
+}
+
+func (d deliveryStore) Remove(ctx context.Context, tenantID int64, deliveryID string) error {
+	const q = `DELETE FROM deliveries WHERE tenant_id = $1 AND delivery_id = $2`
+	_, err := d.db.ExecContext(ctx, q, tenantID, deliveryID)
+	return err
+}
+
+func (d deliveryStore) Expire(ctx context.Context, now time.Time) error {
+	const q = `DELETE FROM deliveries WHERE expires_at < $1`
+	_, err := d.db.ExecContext(ctx, q, now.UTC())
+	return err
+}
@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/bobg/sqlutil"
+	"github.com/pkg/errors"
+
+	"spreche"
+)
+
+type groupStore struct {
+	db *sql.DB
+}
+
+var _ spreche.GroupStore = groupStore{}
+
+func (g groupStore) ByGHTeam(ctx context.Context, tenantID int64, org, slug string) (*spreche.Group, error) {
+	const q = `SELECT slack_group_id FROM team_groups WHERE tenant_id = $1 AND gh_org = $2 AND gh_team = $3`
+	result := &spreche.Group{
+		GHOrg:  org,
+		GHTeam: slug,
+	}
+	err := sqlutil.QueryRowContext(ctx, g.db, q, tenantID, org, slug).Scan(&result.SlackGroupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = spreche.ErrNotFound
+	}
+	return result, err
+}
+
+func (g groupStore) Add(ctx context.Context, tenantID int64, group *spreche.Group) error {
+	const q = `
+		INSERT INTO team_groups (tenant_id, gh_org, gh_team, slack_group_id) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (tenant_id, gh_org, gh_team) DO UPDATE SET slack_group_id = excluded.slack_group_id
+	`
+	_, err := g.db.ExecContext(ctx, q, tenantID, group.GHOrg, group.GHTeam, group.SlackGroupID)
+	return err
+}
+
+func (g groupStore) Remove(ctx context.Context, tenantID int64, org, slug string) error {
+	const q = `DELETE FROM team_groups WHERE tenant_id = $1 AND gh_org = $2 AND gh_team = $3`
+	_, err := g.db.ExecContext(ctx, q, tenantID, org, slug)
+	return err
+}
+
+func (g groupStore) Foreach(ctx context.Context, tenantID int64, f func(*spreche.Group) error) error {
+	const q = `SELECT gh_org, gh_team, slack_group_id FROM team_groups WHERE tenant_id = $1 ORDER BY gh_org, gh_team`
+	return sqlutil.ForQueryRows(ctx, g.db, q, tenantID, func(org, slug, groupID string) error {
+		return f(&spreche.Group{
+			GHOrg:        org,
+			GHTeam:       slug,
+			SlackGroupID: groupID,
+		})
+	})
+}
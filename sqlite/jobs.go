@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/bobg/sqlutil"
+	"github.com/pkg/errors"
+
+	"spreche"
+)
+
+type jobStore struct {
+	db *sql.DB
+}
+
+var _ spreche.JobStore = jobStore{}
+
+func (j jobStore) Add(ctx context.Context, job *spreche.Job) error {
+	const q = `INSERT INTO jobs (kind, event_type, delivery_id, payload, state, attempts, run_at, last_error) VALUES ($1, $2, $3, $4, $5, 0, $6, '')`
+	job.State = spreche.JobPending
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	job.RunAt = job.RunAt.UTC()
+	res, err := j.db.ExecContext(ctx, q, job.Kind, job.EventType, job.DeliveryID, job.Payload, job.State, job.RunAt)
+	if err != nil {
+		return errors.Wrap(err, "inserting job row")
+	}
+	job.JobID, err = res.LastInsertId()
+	return errors.Wrap(err, "getting last insert ID")
+}
+
+func (j jobStore) Claim(ctx context.Context, now time.Time) (*spreche.Job, error) {
+	const q = `
+		UPDATE jobs SET state = $1, attempts = attempts + 1
+			WHERE job_id = (
+				SELECT job_id FROM jobs WHERE state = $2 AND run_at <= $3 ORDER BY run_at, job_id LIMIT 1
+			)
+			RETURNING job_id, kind, event_type, delivery_id, payload, attempts, run_at, last_error
+	`
+	result := &spreche.Job{State: spreche.JobRunning}
+	err := sqlutil.QueryRowContext(ctx, j.db, q, spreche.JobRunning, spreche.JobPending, now.UTC()).Scan(
+		&result.JobID,
+		&result.Kind,
+		&result.EventType,
+		&result.DeliveryID,
+		&result.Payload,
+		&result.Attempts,
+		&result.RunAt,
+		&result.LastError,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = spreche.ErrNotFound
+	}
+	return result, err
+}
+
+func (j jobStore) Done(ctx context.Context, jobID int64) error {
+	const q = `DELETE FROM jobs WHERE job_id = $1`
+	_, err := j.db.ExecContext(ctx, q, jobID)
+	return err
+}
+
+func (j jobStore) Retry(ctx context.Context, jobID int64, runAt time.Time, errmsg string) error {
+	const q = `UPDATE jobs SET state = $1, run_at = $2, last_error = $3 WHERE job_id = $4`
+	_, err := j.db.ExecContext(ctx, q, spreche.JobPending, runAt.UTC(), errmsg, jobID)
+	return err
+}
+
+func (j jobStore) Kill(ctx context.Context, jobID int64, errmsg string) error {
+	const q = `UPDATE jobs SET state = $1, last_error = $2 WHERE job_id = $3`
+	_, err := j.db.ExecContext(ctx, q, spreche.JobDead, errmsg, jobID)
+	return err
+}
+
+func (j jobStore) Requeue(ctx context.Context, jobID int64) error {
+	const q = `UPDATE jobs SET state = $1, attempts = 0, run_at = $2 WHERE job_id = $3 AND state = $4`
+	res, err := j.db.ExecContext(ctx, q, spreche.JobPending, time.Now().UTC(), jobID, spreche.JobDead)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "counting affected rows")
+	}
+	if n == 0 {
+		return spreche.ErrNotFound
+	}
+	return nil
+}
+
+func (j jobStore) Reset(ctx context.Context) error {
+	const q = `UPDATE jobs SET state = $1 WHERE state = $2`
+	_, err := j.db.ExecContext(ctx, q, spreche.JobPending, spreche.JobRunning)
+	return err
+}
+
+func (j jobStore) Foreach(ctx context.Context, state string, f func(*spreche.Job) error) error {
+	const q = `SELECT job_id, kind, event_type, delivery_id, payload, attempts, run_at, last_error FROM jobs WHERE state = $1 ORDER BY run_at, job_id`
+	return sqlutil.ForQueryRows(ctx, j.db, q, state, func(jobID int64, kind, eventType, deliveryID string, payload []byte, attempts int, runAt time.Time, lastError string) error {
+		return f(&spreche.Job{
+			JobID:      jobID,
+			Kind:       kind,
+			EventType:  eventType,
+			DeliveryID: deliveryID,
+			Payload:    payload,
+			State:      state,
+			Attempts:   attempts,
+			RunAt:      runAt,
+			LastError:  lastError,
+		})
+	})
+}
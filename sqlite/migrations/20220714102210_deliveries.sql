@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN delivery_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS deliveries (
+  tenant_id INTEGER NOT NULL,
+  delivery_id TEXT NOT NULL,
+  expires_at DATETIME NOT NULL,
+  PRIMARY KEY (tenant_id, delivery_id)
+);
+
+CREATE INDEX IF NOT EXISTS deliveries_expires_at_index ON deliveries (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE deliveries;
+ALTER TABLE jobs DROP COLUMN delivery_id;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE channels ADD COLUMN state TEXT NOT NULL DEFAULT 'open';
+ALTER TABLE channels ADD COLUMN closed_at DATETIME;
+ALTER TABLE channels ADD COLUMN archived_at DATETIME;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE channels DROP COLUMN archived_at;
+ALTER TABLE channels DROP COLUMN closed_at;
+ALTER TABLE channels DROP COLUMN state;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tenants ADD COLUMN state TEXT NOT NULL DEFAULT 'active';
+CREATE INDEX IF NOT EXISTS gh_installation_id_index ON tenants (gh_installation_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS gh_installation_id_index;
+ALTER TABLE tenants DROP COLUMN state;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Put API URLs in the canonical form used for installation lookups.
+UPDATE tenants SET gh_api_url = gh_api_url || '/' WHERE gh_api_url NOT LIKE '%/';
+UPDATE tenants SET gh_api_url = gh_api_url || 'api/v3/' WHERE gh_api_url <> 'https://api.github.com/' AND gh_api_url NOT LIKE '%/api/v3/';
+
+-- Installation IDs are unique only per app and GitHub server.
+-- Tenants sharing an installation cannot be merged automatically,
+-- since each may have its own repos, teams, channels, and secrets.
+-- If there are any, this migration fails.
+-- Find them with:
+--   SELECT gh_api_url, gh_app_id, gh_installation_id, GROUP_CONCAT(tenant_id) FROM tenants
+--     WHERE gh_installation_id <> 0 GROUP BY 1, 2, 3 HAVING COUNT(*) > 1;
+-- then move what is worth keeping to one of them,
+-- delete the others or detach them from the installation (setting gh_installation_id to 0),
+-- and run the migration again.
+CREATE TEMP TABLE duplicate_installations (n INTEGER);
+CREATE TEMP TRIGGER duplicate_installations_check BEFORE INSERT ON duplicate_installations WHEN NEW.n > 0
+BEGIN
+  SELECT RAISE(ABORT, 'several tenants have the same GitHub installation; see migration 20220813094412_tenant_installation_key.sql for how to fix them');
+END;
+INSERT INTO duplicate_installations
+  SELECT COUNT(*) FROM (
+    SELECT 1 FROM tenants WHERE gh_installation_id <> 0 GROUP BY gh_api_url, gh_app_id, gh_installation_id HAVING COUNT(*) > 1
+  );
+DROP TABLE duplicate_installations;
+
+DROP INDEX IF EXISTS gh_installation_id_index;
+CREATE UNIQUE INDEX IF NOT EXISTS gh_installation_index ON tenants (gh_api_url, gh_app_id, gh_installation_id) WHERE gh_installation_id <> 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS gh_installation_index;
+CREATE INDEX IF NOT EXISTS gh_installation_id_index ON tenants (gh_installation_id);
+-- +goose StatementEnd
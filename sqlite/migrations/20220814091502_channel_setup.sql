@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE channels ADD COLUMN setup_pending BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE channels ADD COLUMN backfill_pending BOOLEAN NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE channels DROP COLUMN backfill_pending;
+ALTER TABLE channels DROP COLUMN setup_pending;
+-- +goose StatementEnd
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/bobg/sqlutil"
+	"github.com/pkg/errors"
+
+	"spreche"
+)
+
+type reactionStore struct {
+	db *sql.DB
+}
+
+var _ spreche.ReactionStore = reactionStore{}
+
+func (r reactionStore) Add(ctx context.Context, tenantID int64, rec *spreche.Reaction) error {
+	const q = `
+		INSERT INTO reactions (tenant_id, channel_id, timestamp, slack_user_id, slack_reaction, target, target_id, reaction_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (tenant_id, channel_id, timestamp, slack_user_id, slack_reaction) DO UPDATE SET target = excluded.target, target_id = excluded.target_id, reaction_id = excluded.reaction_id
+	`
+	_, err := r.db.ExecContext(ctx, q, tenantID, rec.ChannelID, rec.Timestamp, rec.SlackUserID, rec.SlackReaction, rec.Target, rec.TargetID, rec.ReactionID)
+	return err
+}
+
+func (r reactionStore) Get(ctx context.Context, tenantID int64, channelID, timestamp, slackUserID, slackReaction string) (*spreche.Reaction, error) {
+	const q = `SELECT target, target_id, reaction_id FROM reactions WHERE tenant_id = $1 AND channel_id = $2 AND timestamp = $3 AND slack_user_id = $4 AND slack_reaction = $5`
+	result := &spreche.Reaction{
+		ChannelID:     channelID,
+		Timestamp:     timestamp,
+		SlackUserID:   slackUserID,
+		SlackReaction: slackReaction,
+	}
+	err := sqlutil.QueryRowContext(ctx, r.db, q, tenantID, channelID, timestamp, slackUserID, slackReaction).Scan(&result.Target, &result.TargetID, &result.ReactionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = spreche.ErrNotFound
+	}
+	return result, err
+}
+
+func (r reactionStore) Remove(ctx context.Context, tenantID int64, channelID, timestamp, slackUserID, slackReaction string) (*spreche.Reaction, int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Deleting first takes SQLite's write lock,
+	// so concurrent removals are serialized.
+	const qDelete = `
+		DELETE FROM reactions WHERE tenant_id = $1 AND channel_id = $2 AND timestamp = $3 AND slack_user_id = $4 AND slack_reaction = $5
+			RETURNING target, target_id, reaction_id
+	`
+	rec := &spreche.Reaction{
+		ChannelID:     channelID,
+		Timestamp:     timestamp,
+		SlackUserID:   slackUserID,
+		SlackReaction: slackReaction,
+	}
+	err = sqlutil.QueryRowContext(ctx, tx, qDelete, tenantID, channelID, timestamp, slackUserID, slackReaction).Scan(&rec.Target, &rec.TargetID, &rec.ReactionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, spreche.ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "deleting reaction record")
+	}
+
+	const qCount = `SELECT COUNT(*) FROM reactions WHERE tenant_id = $1 AND reaction_id = $2`
+	var n int
+	if err = sqlutil.QueryRowContext(ctx, tx, qCount, tenantID, rec.ReactionID).Scan(&n); err != nil {
+		return nil, 0, errors.Wrap(err, "counting reaction records")
+	}
+
+	return rec, n, errors.Wrap(tx.Commit(), "committing transaction")
+}
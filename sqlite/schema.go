@@ -13,10 +13,17 @@ import (
 )
 
 type Stores struct {
-	Channels spreche.ChannelStore
-	Comments spreche.CommentStore
-	Tenants  spreche.TenantStore
-	Users    spreche.UserStore
+	BotRules   spreche.BotRuleStore
+	Channels   spreche.ChannelStore
+	Checks     spreche.CheckStore
+	Comments   spreche.CommentStore
+	Deliveries spreche.DeliveryStore
+	Groups     spreche.GroupStore
+	Jobs       spreche.JobStore
+	Reactions  spreche.ReactionStore
+	Secrets    spreche.SecretStore
+	Tenants    spreche.TenantStore
+	Users      spreche.UserStore
 
 	db *sql.DB
 }
@@ -40,11 +47,18 @@ func Open(ctx context.Context, conn string) (stores Stores, err error) {
 	}
 	err = goose.Up(db, "migrations")
 	return Stores{
-		Channels: channelStore{db: db},
-		Comments: commentStore{db: db},
-		Tenants:  tenantStore{db: db},
-		Users:    userStore{db: db},
-		db:       db,
+		BotRules:   botRuleStore{db: db},
+		Channels:   channelStore{db: db},
+		Checks:     checkStore{db: db},
+		Comments:   commentStore{db: db},
+		Deliveries: deliveryStore{db: db},
+		Groups:     groupStore{db: db},
+		Jobs:       jobStore{db: db},
+		Reactions:  reactionStore{db: db},
+		Secrets:    secretStore{db: db},
+		Tenants:    tenantStore{db: db},
+		Users:      userStore{db: db},
+		db:         db,
 	}, errors.Wrap(err, "performing db migrations")
 }
 
@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/bobg/sqlutil"
+	"github.com/pkg/errors"
+
+	"spreche"
+)
+
+type secretStore struct {
+	db *sql.DB
+}
+
+var _ spreche.SecretStore = secretStore{}
+
+func (s secretStore) Add(ctx context.Context, secret *spreche.Secret) error {
+	const q = `INSERT INTO tenant_secrets (tenant_id, kind, secret, created_at) VALUES ($1, $2, $3, $4)`
+	if secret.CreatedAt.IsZero() {
+		secret.CreatedAt = time.Now()
+	}
+	secret.CreatedAt = secret.CreatedAt.UTC()
+	res, err := s.db.ExecContext(ctx, q, secret.TenantID, secret.Kind, secret.Secret, secret.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "inserting secret row")
+	}
+	secret.SecretID, err = res.LastInsertId()
+	return errors.Wrap(err, "getting last insert ID")
+}
+
+func (s secretStore) Retire(ctx context.Context, tenantID, secretID int64) error {
+	const q = `DELETE FROM tenant_secrets WHERE tenant_id = $1 AND secret_id = $2`
+	res, err := s.db.ExecContext(ctx, q, tenantID, secretID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "counting affected rows")
+	}
+	if n == 0 {
+		return spreche.ErrNotFound
+	}
+	return nil
+}
+
+func (s secretStore) Foreach(ctx context.Context, tenantID int64, kind string, f func(*spreche.Secret) error) error {
+	const q = `SELECT secret_id, kind, secret, created_at FROM tenant_secrets WHERE tenant_id = $1 AND ($2 = '' OR kind = $2) ORDER BY created_at DESC, secret_id DESC`
+	return sqlutil.ForQueryRows(ctx, s.db, q, tenantID, kind, func(secretID int64, kind, secret string, createdAt time.Time) error {
+		return f(&spreche.Secret{
+			SecretID:  secretID,
+			TenantID:  tenantID,
+			Kind:      kind,
+			Secret:    secret,
+			CreatedAt: createdAt,
+		})
+	})
+}
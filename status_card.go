@@ -0,0 +1,277 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// The status card is the pinned message at the top of each PR channel
+// (the one whose timestamp is Channel.PRBodyTS).
+// It shows the PR body under a summary of the PR's current state,
+// and is updated in place as the PR changes.
+
+// reviewerState is the latest review state of a single reviewer.
+type reviewerState struct {
+	login, htmlURL string
+
+	// state is one of the GitHub review states
+	// (APPROVED, CHANGES_REQUESTED, COMMENTED, DISMISSED),
+	// or "REQUESTED" for a pending review request.
+	state string
+}
+
+// postStatusCard posts and pins a new status card in the given channel,
+// returning its timestamp.
+func (s *Service) postStatusCard(ctx context.Context, tenant *Tenant, channelID string, pr *github.PullRequest) (string, error) {
+	reviewers, err := s.reviewerStates(ctx, tenant, pr)
+	if err != nil {
+		return "", err
+	}
+	users, err := s.ghUserMap(ctx, tenant.TenantID, pr.GetBody())
+	if err != nil {
+		return "", err
+	}
+	refs, err := newGHRefs(tenant.GHAPIURL, pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName())
+	if err != nil {
+		return "", err
+	}
+	ts, err := s.postToSlack(ctx, tenant, channelID, 0, "", statusCardOptions(tenant, pr, reviewers, users, refs)...)
+	if err != nil {
+		return "", err
+	}
+
+	// Neither pinning the card nor proxying its images is essential,
+	// so a failure of either must not fail the creation of the channel
+	// (whose retry could not create it again).
+	sc := tenant.SlackClient()
+	if err = sc.AddPinContext(ctx, channelID, slack.NewRefToMessage(channelID, ts)); err != nil {
+		log.Printf("Error pinning status card in channel %s: %s", channelID, err)
+	}
+	s.tryProxyGHImages(ctx, tenant, channelID, ts, pr.GetBody(), "")
+
+	return ts, nil
+}
+
+// updateStatusCard re-renders the status card in a channel from the given PR.
+// If the PR lacks its merge status,
+// as in the payloads of events other than pull_request,
+// it is fetched afresh.
+func (s *Service) updateStatusCard(ctx context.Context, tenant *Tenant, channel *Channel, pr *github.PullRequest) error {
+	if channel.PRBodyTS == "" {
+		return nil
+	}
+	if pr.MergeableState == nil {
+		gh, err := tenant.GHClient()
+		if err != nil {
+			return errors.Wrap(err, "getting GitHub client")
+		}
+		pr, _, err = gh.PullRequests.Get(ctx, channel.Owner, channel.Repo, channel.PR)
+		if err != nil {
+			return errors.Wrapf(err, "getting PR %d", channel.PR)
+		}
+	}
+	reviewers, err := s.reviewerStates(ctx, tenant, pr)
+	if err != nil {
+		return err
+	}
+	users, err := s.ghUserMap(ctx, tenant.TenantID, pr.GetBody())
+	if err != nil {
+		return err
+	}
+	refs, err := newGHRefs(tenant.GHAPIURL, pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName())
+	if err != nil {
+		return err
+	}
+	sc := tenant.SlackClient()
+	_, _, _, err = sc.UpdateMessageContext(ctx, channel.ChannelID, channel.PRBodyTS, statusCardOptions(tenant, pr, reviewers, users, refs)...)
+	return errors.Wrap(err, "updating status card")
+}
+
+// reviewerStates gets the latest review state of each reviewer of a PR,
+// plus any pending review requests.
+func (s *Service) reviewerStates(ctx context.Context, tenant *Tenant, pr *github.PullRequest) ([]reviewerState, error) {
+	gh, err := tenant.GHClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting GitHub client")
+	}
+
+	var (
+		owner   = pr.GetBase().GetRepo().GetOwner().GetLogin()
+		repo    = pr.GetBase().GetRepo().GetName()
+		byLogin = make(map[string]*reviewerState)
+	)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := gh.PullRequests.ListReviews(ctx, owner, repo, pr.GetNumber(), opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "listing reviews")
+		}
+		// Reviews are listed in chronological order.
+		// A COMMENTED review does not supersede an earlier verdict.
+		for _, r := range reviews {
+			login := r.GetUser().GetLogin()
+			if login == "" || login == pr.GetUser().GetLogin() {
+				continue
+			}
+			rs, ok := byLogin[login]
+			if !ok {
+				rs = &reviewerState{login: login, htmlURL: r.GetUser().GetHTMLURL()}
+				byLogin[login] = rs
+			}
+			if r.GetState() == "COMMENTED" && rs.state != "" {
+				continue
+			}
+			rs.state = r.GetState()
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	for _, u := range pr.RequestedReviewers {
+		login := u.GetLogin()
+		rs, ok := byLogin[login]
+		if !ok {
+			rs = &reviewerState{login: login, htmlURL: u.GetHTMLURL()}
+			byLogin[login] = rs
+		}
+		rs.state = "REQUESTED"
+	}
+
+	var result []reviewerState
+	for _, rs := range byLogin {
+		result = append(result, *rs)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].login < result[j].login })
+
+	return result, nil
+}
+
+func statusCardOptions(tenant *Tenant, pr *github.PullRequest, reviewers []reviewerState, users *userMap, refs *ghRefs) []slack.MsgOption {
+	blocks := statusCardBlocks(pr, reviewers, users, refs)
+	if tenant.ProxyImages {
+		blocks = withoutImageBlocks(blocks, refs.htmlBase)
+	}
+	return []slack.MsgOption{
+		slack.MsgOptionDisableLinkUnfurl(),
+		slack.MsgOptionText(fmt.Sprintf("%s: %s", pr.GetHTMLURL(), pr.GetTitle()), false),
+		slack.MsgOptionBlocks(blocks...),
+	}
+}
+
+func statusCardBlocks(pr *github.PullRequest, reviewers []reviewerState, users *userMap, refs *ghRefs) []slack.Block {
+	field := func(label, value string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s", label, value), false, false)
+	}
+
+	var (
+		labels    []string
+		assignees []string
+	)
+	for _, l := range pr.Labels {
+		labels = append(labels, "`"+l.GetName()+"`")
+	}
+	for _, a := range pr.Assignees {
+		assignees = append(assignees, fmt.Sprintf("<%s|%s>", a.GetHTMLURL(), a.GetLogin()))
+	}
+
+	state := pr.GetState()
+	if pr.GetMerged() {
+		state = StateMerged
+	}
+	draft := "no"
+	if pr.GetDraft() {
+		draft = "yes"
+	}
+	mergeable := "unknown"
+	if pr.Mergeable != nil {
+		if *pr.Mergeable {
+			mergeable = "yes"
+		} else {
+			mergeable = "no"
+		}
+		if ms := pr.GetMergeableState(); ms != "" && ms != "clean" {
+			mergeable += fmt.Sprintf(" (%s)", ms)
+		}
+	}
+
+	fields := []*slack.TextBlockObject{
+		field("Author", fmt.Sprintf("<%s|%s>", pr.GetUser().GetHTMLURL(), pr.GetUser().GetLogin())),
+		field("Branches", fmt.Sprintf("`%s` → `%s`", pr.GetHead().GetRef(), pr.GetBase().GetRef())),
+		field("Labels", joinOrNone(labels)),
+		field("Assignees", joinOrNone(assignees)),
+		field("State", state),
+		field("Draft", draft),
+		field("Mergeable", mergeable),
+	}
+
+	var reviewLines []string
+	for _, rs := range reviewers {
+		reviewLines = append(reviewLines, fmt.Sprintf("%s <%s|%s> %s", reviewStateEmoji(rs.state), rs.htmlURL, rs.login, reviewStateText(rs.state)))
+	}
+	reviewText := "_none yet_"
+	if len(reviewLines) > 0 {
+		reviewText = strings.Join(reviewLines, "\n")
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*<%s|%s>*", pr.GetHTMLURL(), pr.GetTitle()), false, false), nil, nil),
+		slack.NewSectionBlock(nil, fields, nil),
+		slack.NewSectionBlock(field("Reviews", reviewText), nil, nil),
+		slack.NewDividerBlock(),
+	}
+
+	body := "[no content]"
+	if pr.Body != nil && *pr.Body != "" {
+		body = *pr.Body
+	}
+	return append(blocks, ghMarkdownToSlack([]byte(body), users, refs)...)
+}
+
+func joinOrNone(strs []string) string {
+	if len(strs) == 0 {
+		return "_none_"
+	}
+	return strings.Join(strs, ", ")
+}
+
+func reviewStateEmoji(state string) string {
+	switch state {
+	case "APPROVED":
+		return ":white_check_mark:"
+	case "CHANGES_REQUESTED":
+		return ":x:"
+	case "COMMENTED":
+		return ":speech_balloon:"
+	case "DISMISSED":
+		return ":heavy_minus_sign:"
+	case "REQUESTED":
+		return ":hourglass_flowing_sand:"
+	}
+	return ":grey_question:"
+}
+
+func reviewStateText(state string) string {
+	switch state {
+	case "APPROVED":
+		return "approved"
+	case "CHANGES_REQUESTED":
+		return "requested changes"
+	case "COMMENTED":
+		return "commented"
+	case "DISMISSED":
+		return "review dismissed"
+	case "REQUESTED":
+		return "review requested"
+	}
+	return strings.ToLower(state)
+}
@@ -0,0 +1,40 @@
+package spreche
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v45/github"
+)
+
+func TestUpdateStatusCardRefetch(t *testing.T) {
+	const channelID = "C0PRCHAN"
+
+	s, api := newFakeService(t, channelID)
+
+	ctx := context.Background()
+	tenant := s.Tenants.(fakeTenantStore).tenant
+	channel := &Channel{
+		ChannelID: channelID,
+		Owner:     "bobg",
+		Repo:      "spreche",
+		PR:        17,
+		PRBodyTS:  "1.000000",
+	}
+
+	// A PR from a pull_request_review payload has no merge status.
+	if err := s.updateStatusCard(ctx, tenant, channel, &github.PullRequest{Number: github.Int(17)}); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("GET /api/v3/repos/bobg/spreche/pulls/17"); n != 1 {
+		t.Errorf("got %d PR fetches for partial PR, want 1", n)
+	}
+
+	full := &github.PullRequest{Number: github.Int(17), MergeableState: github.String("clean"), Mergeable: github.Bool(true)}
+	if err := s.updateStatusCard(ctx, tenant, channel, full); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("GET /api/v3/repos/bobg/spreche/pulls/17"); n != 1 {
+		t.Errorf("got %d PR fetches after full PR, want still 1", n)
+	}
+}
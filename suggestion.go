@@ -0,0 +1,252 @@
+package spreche
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// A review comment may contain suggested changes to the commented lines,
+// in ```suggestion fences.
+// These are rendered in Slack as diffs against the commented lines,
+// each with a button for committing it to the PR's head branch.
+
+// suggestionActionID is the action ID of the button for committing a suggestion.
+// Its value is "COMMENTID/INDEX",
+// where INDEX is the position of the suggestion among those in the comment.
+const suggestionActionID = "commit_suggestion"
+
+var suggestionRegex = regexp.MustCompile("(?ms)^[ \t]*```suggestion[^\n]*\n(.*?)^[ \t]*```[ \t]*$")
+
+// splitSuggestions splits a comment body around its suggestion fences.
+// It returns the text surrounding the suggestions (one more than the number of suggestions)
+// and the lines of each suggestion.
+func splitSuggestions(body string) (texts []string, suggestions [][]string) {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+
+	var pos int
+	for _, m := range suggestionRegex.FindAllStringSubmatchIndex(body, -1) {
+		texts = append(texts, body[pos:m[0]])
+		content := strings.TrimSuffix(body[m[2]:m[3]], "\n")
+		var lines []string
+		if content != "" {
+			lines = strings.Split(content, "\n")
+		}
+		suggestions = append(suggestions, lines)
+		pos = m[1]
+	}
+	return append(texts, body[pos:]), suggestions
+}
+
+// commentBodyBlocks renders the body of a GitHub comment as Slack blocks,
+// with any suggestions rendered as diffs.
+func commentBodyBlocks(c ghComment, users *userMap, refs *ghRefs) []slack.Block {
+	if c.hunk == nil {
+		return ghMarkdownToSlack([]byte(c.body), users, refs)
+	}
+	texts, suggestions := splitSuggestions(c.body)
+	if len(suggestions) == 0 {
+		return ghMarkdownToSlack([]byte(c.body), users, refs)
+	}
+
+	oldLines, ok := c.hunk.commentedLines()
+	canCommit := ok && !c.hunk.outdated && c.hunk.side == "RIGHT"
+
+	var blocks []slack.Block
+	for i, text := range texts {
+		if strings.TrimSpace(text) != "" {
+			blocks = append(blocks, ghMarkdownToSlack([]byte(text), users, refs)...)
+		}
+		if i == len(suggestions) {
+			break
+		}
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", "*Suggested change*\n"+suggestionDiffMrkdwn(oldLines, suggestions[i]), false, false),
+			nil,
+			nil,
+		))
+		if canCommit {
+			button := slack.NewButtonBlockElement(
+				suggestionActionID,
+				fmt.Sprintf("%d/%d", c.commentID, i),
+				slack.NewTextBlockObject(slack.PlainTextType, "Commit suggestion", false, false),
+			)
+			blocks = append(blocks, slack.NewActionBlock("", button))
+		}
+	}
+	return blocks
+}
+
+// suggestionDiffMrkdwn renders a suggestion as a diff against the lines it replaces,
+// in a Slack mrkdwn code block.
+func suggestionDiffMrkdwn(oldLines, newLines []string) string {
+	var lines []string
+	for _, l := range oldLines {
+		lines = append(lines, codeEscape("-"+l))
+	}
+	for _, l := range newLines {
+		lines = append(lines, codeEscape("+"+l))
+	}
+	if len(lines) == 0 {
+		return "_(no change)_"
+	}
+	return "```\n" + strings.Join(lines, "\n") + "\n```"
+}
+
+// commitSuggestion handles a click on a suggestion's commit button.
+// It commits the suggestion to the PR's head branch,
+// provided the clicking Slack user is linked to a GitHub user with write access to the repo,
+// and the commented lines are unchanged on the branch.
+// Problems the user can do something about are reported to them with an ephemeral message.
+func (s *Service) commitSuggestion(ctx context.Context, tenant *Tenant, cb *slack.InteractionCallback, value string) error {
+	commentIDStr, indexStr, _ := strings.Cut(value, "/")
+	commentID, err := strconv.ParseInt(commentIDStr, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "parsing comment ID in action value %s", value)
+	}
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		return errors.Wrapf(err, "parsing suggestion index in action value %s", value)
+	}
+
+	sc := tenant.SlackClient()
+
+	tell := func(msg string) error {
+		_, err := sc.PostEphemeralContext(ctx, cb.Channel.ID, cb.User.ID, slack.MsgOptionText(msg, false))
+		return errors.Wrap(err, "posting ephemeral message")
+	}
+
+	channel, err := s.Channels.ByChannelID(ctx, tenant.TenantID, cb.Channel.ID)
+	if err != nil {
+		return errors.Wrapf(err, "getting info for channelID %s", cb.Channel.ID)
+	}
+
+	user, err := s.Users.BySlackID(ctx, tenant.TenantID, cb.User.ID)
+	if errors.Is(err, ErrNotFound) {
+		return tell("Your Slack account is not linked to a GitHub account, so you cannot commit suggestions from here.")
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting info for userID %s", cb.User.ID)
+	}
+
+	gh, err := tenant.GHClient()
+	if err != nil {
+		return errors.Wrap(err, "getting GitHub client")
+	}
+
+	perm, _, err := gh.Repositories.GetPermissionLevel(ctx, channel.Owner, channel.Repo, user.GHLogin)
+	if err != nil {
+		return errors.Wrapf(err, "getting permission level of %s", user.GHLogin)
+	}
+	if p := perm.GetPermission(); p != "admin" && p != "write" {
+		return tell(fmt.Sprintf("GitHub user %s does not have write access to %s/%s.", user.GHLogin, channel.Owner, channel.Repo))
+	}
+
+	comment, _, err := gh.PullRequests.GetComment(ctx, channel.Owner, channel.Repo, commentID)
+	if err != nil {
+		return errors.Wrapf(err, "getting review comment %d", commentID)
+	}
+	h := reviewCommentHunkRef(comment)
+	if h == nil || h.outdated || h.side != "RIGHT" {
+		return tell("That suggestion no longer applies to the PR's code.")
+	}
+	oldLines, ok := h.commentedLines()
+	if !ok {
+		return tell("Could not find the lines that suggestion replaces.")
+	}
+	_, suggestions := splitSuggestions(comment.GetBody())
+	if index < 0 || index >= len(suggestions) {
+		return tell("That suggestion is no longer in the comment.")
+	}
+
+	pr, _, err := gh.PullRequests.Get(ctx, channel.Owner, channel.Repo, channel.PR)
+	if err != nil {
+		return errors.Wrapf(err, "getting PR %d", channel.PR)
+	}
+	if pr.GetState() != "open" {
+		return tell("The PR is no longer open.")
+	}
+	var (
+		headOwner = pr.GetHead().GetRepo().GetOwner().GetLogin()
+		headRepo  = pr.GetHead().GetRepo().GetName()
+		headRef   = pr.GetHead().GetRef()
+	)
+
+	file, _, _, err := gh.Repositories.GetContents(ctx, headOwner, headRepo, h.path, &github.RepositoryContentGetOptions{Ref: headRef})
+	if err != nil {
+		return errors.Wrapf(err, "getting %s on branch %s", h.path, headRef)
+	}
+	if file == nil {
+		return fmt.Errorf("%s is not a file", h.path)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return errors.Wrapf(err, "decoding %s", h.path)
+	}
+
+	first := h.startLine
+	if first == 0 {
+		first = h.line
+	}
+	fileLines := strings.Split(content, "\n")
+	if h.line > len(fileLines) || !equalLines(fileLines[first-1:h.line], oldLines) {
+		return tell(fmt.Sprintf("The commented lines of %s have changed on branch %s since the suggestion was made.", h.path, headRef))
+	}
+
+	var newLines []string
+	newLines = append(newLines, fileLines[:first-1]...)
+	newLines = append(newLines, suggestions[index]...)
+	newLines = append(newLines, fileLines[h.line:]...)
+
+	msg := fmt.Sprintf("Apply suggestion from code review\n\nSuggested by @%s in %s\nCommitted from Slack by @%s", comment.GetUser().GetLogin(), comment.GetHTMLURL(), user.GHLogin)
+	resp, _, err := gh.Repositories.UpdateFile(ctx, headOwner, headRepo, h.path, &github.RepositoryContentFileOptions{
+		Message: &msg,
+		Content: []byte(strings.Join(newLines, "\n")),
+		SHA:     file.SHA,
+		Branch:  &headRef,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "committing suggestion to %s", headRef)
+	}
+
+	// Announce the commit in the comment's thread.
+	rec, err := s.Comments.ByCommentID(ctx, tenant.TenantID, channel.ChannelID, commentID)
+	if err != nil {
+		return errors.Wrap(err, "getting comment record")
+	}
+	root, err := threadRoot(ctx, sc, channel.ChannelID, rec.ThreadTimestamp)
+	if err != nil {
+		return err
+	}
+	sha := resp.GetSHA()
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	notice := fmt.Sprintf("_Suggestion committed by <@%s> in <%s|`%s`>_", cb.User.ID, resp.GetHTMLURL(), sha)
+	_, err = s.postToSlack(ctx, tenant, channel.ChannelID, 0, "",
+		slack.MsgOptionTS(root),
+		slack.MsgOptionText(notice, false),
+		slack.MsgOptionBlocks(slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", notice, false, false))),
+	)
+	return errors.Wrap(err, "posting to Slack")
+}
+
+// equalLines compares lines of a file with the corresponding lines of a diff hunk,
+// ignoring carriage returns.
+func equalLines(fileLines, hunkLines []string) bool {
+	if len(fileLines) != len(hunkLines) {
+		return false
+	}
+	for i, l := range fileLines {
+		if strings.TrimSuffix(l, "\r") != strings.TrimSuffix(hunkLines[i], "\r") {
+			return false
+		}
+	}
+	return true
+}
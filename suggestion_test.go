@@ -0,0 +1,41 @@
+package spreche
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSuggestions(t *testing.T) {
+	cases := []struct {
+		name            string
+		body            string
+		wantTexts       []string
+		wantSuggestions [][]string
+	}{{
+		name:      "none",
+		body:      "Looks good.\n```go\nx := 1\n```\n",
+		wantTexts: []string{"Looks good.\n```go\nx := 1\n```\n"},
+	}, {
+		name:            "one",
+		body:            "Try this:\r\n```suggestion\r\nb := \"x\"\r\nc := b\r\n```\r\nThanks!",
+		wantTexts:       []string{"Try this:\n", "\nThanks!"},
+		wantSuggestions: [][]string{{`b := "x"`, "c := b"}},
+	}, {
+		name:            "deletion_and_indented_fence",
+		body:            "```suggestion\n```\nor\n  ```suggestion\n\tx++\n  ```",
+		wantTexts:       []string{"", "\nor\n", ""},
+		wantSuggestions: [][]string{nil, {"\tx++"}},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			texts, suggestions := splitSuggestions(c.body)
+			if !reflect.DeepEqual(texts, c.wantTexts) {
+				t.Errorf("got texts %q, want %q", texts, c.wantTexts)
+			}
+			if !reflect.DeepEqual(suggestions, c.wantSuggestions) {
+				t.Errorf("got suggestions %q, want %q", suggestions, c.wantSuggestions)
+			}
+		})
+	}
+}
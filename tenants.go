@@ -11,11 +11,13 @@ type TenantStore interface {
 	// WithTenant finds a suitable tenant and runs the given callback with it.
 	// If tenantID is non-zero, that's identifies the tenant to use.
 	// Otherwise, one of repoURL and teamID must be specified, and the associated tenant is found.
+	// Only an active tenant (see Tenant.State) is found this way.
 	// If specified, repoURL must be the HTML URL of a GitHub repo.
 	// The tenant may be associated with it directly,
 	// or with its parent (the containing GitHub user or org),
 	// or _its_ parent.
 	// See Tenant.GHURLs.
+	// If there is no such tenant it returns ErrNotFound without calling f.
 	WithTenant(ctx context.Context, tenantID int64, repoURL, teamID string, f func(context.Context, *Tenant) error) error
 
 	// Add adds a new tenant to the store.
@@ -23,7 +25,31 @@ type TenantStore interface {
 	// On a successful return, the TenantID field of the object is populated with the new ID.
 	Add(context.Context, *Tenant) error
 
+	// Update stores the GitHub App ID, Slack token, state, and settings of an existing tenant
+	// (the fields of the given *Tenant from GHAppID on).
+	Update(context.Context, *Tenant) error
+
+	// ByGHInstallation gets the tenant for a GitHub App installation,
+	// whatever its state,
+	// with its GHURLs and TeamIDs.
+	// An installation ID is unique only per app and GitHub server,
+	// so the installation is identified by the API URL of its server
+	// (in the canonical form produced by canonicalGHAPIURL),
+	// its app ID,
+	// and its installation ID.
+	// If there is none it returns ErrNotFound.
+	ByGHInstallation(ctx context.Context, apiURL string, appID, installationID int64) (*Tenant, error)
+
 	AddGHURL(context.Context, int64, string) error
+
+	// RemoveGHURL removes a GitHub URL from those associated with a tenant.
+	RemoveGHURL(ctx context.Context, tenantID int64, ghURL string) error
+
+	// RenameGHURL changes a GitHub URL associated with a tenant,
+	// after the repo it denotes is renamed or transferred.
+	// It is not an error if the tenant is not associated with oldURL.
+	RenameGHURL(ctx context.Context, tenantID int64, oldURL, newURL string) error
+
 	AddTeam(context.Context, int64, string) error
 	Foreach(context.Context, func(*Tenant) error) error
 }
@@ -34,7 +60,37 @@ type Tenant struct {
 	GHPrivKey        []byte `json:"-"`
 	GHAPIURL         string `json:"gh_api_url"`
 	GHUploadURL      string `json:"gh_upload_url"`
-	SlackToken       string `json:"-"`
+
+	// GHAppID is the ID of the GitHub App that the installation belongs to.
+	GHAppID int64 `json:"gh_app_id"`
+
+	SlackToken string `json:"-"`
+
+	// State is TenantActive, TenantPending, or TenantDisabled.
+	State string `json:"state"`
+
+	// DeferDrafts, if true, postpones creating a channel for a draft PR
+	// (and inviting its reviewers)
+	// until the PR is marked ready for review.
+	DeferDrafts bool `json:"defer_drafts"`
+
+	// MarkResolved, if true, adds a reaction to the Slack message for the first comment of a resolved review thread,
+	// and collapses it to a one-line summary.
+	MarkResolved bool `json:"mark_resolved"`
+
+	// DiffContextLines is the number of lines of the diff hunk to show
+	// before the commented lines of a review comment.
+	DiffContextLines int `json:"diff_context_lines"`
+
+	// DeletePolicy says what happens to the GitHub comment for a deleted Slack message:
+	// DeletePolicyTombstone (the default) replaces its body with a notice,
+	// and DeletePolicyDelete deletes it.
+	DeletePolicy string `json:"delete_policy"`
+
+	// ProxyImages, if true, re-uploads the images in GitHub comments to Slack,
+	// for when Slack cannot fetch them from GitHub
+	// (as with private repos and GitHub Enterprise).
+	ProxyImages bool `json:"proxy_images"`
 
 	// GHURLs is a list of GitHub URLs associated with this tenant.
 	// Each URL may be a repo's HTML URL,
@@ -45,6 +101,29 @@ type Tenant struct {
 	TeamIDs []string `json:"team_ids,omitempty"`
 }
 
+// Tenant states.
+// A pending tenant has been created from a GitHub App installation
+// but not yet attached to a Slack team.
+// A disabled tenant's installation has been deleted or suspended.
+const (
+	TenantActive   = "active"
+	TenantPending  = "pending"
+	TenantDisabled = "disabled"
+)
+
+// Values for Tenant.DeletePolicy.
+const (
+	DeletePolicyTombstone = "tombstone"
+	DeletePolicyDelete    = "delete"
+)
+
+// slackAPIURL, when non-empty, overrides the default Slack API endpoint.
+// It is for testing.
+var slackAPIURL string
+
 func (t *Tenant) SlackClient() *slack.Client {
+	if slackAPIURL != "" {
+		return slack.New(t.SlackToken, slack.OptionAPIURL(slackAPIURL))
+	}
 	return slack.New(t.SlackToken)
 }
@@ -0,0 +1,180 @@
+package spreche
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Files shared in Slack are private to the Slack workspace,
+// so GitHub cannot render them from their Slack URLs.
+// Images and text snippets are instead downloaded with the tenant's Slack token
+// and re-hosted with the Service's Uploader,
+// and the GitHub comment embeds the re-hosted copies.
+// Other files, and all files when there is no Uploader,
+// are linked to in Slack.
+
+// Uploader stores files somewhere GitHub can fetch them.
+type Uploader interface {
+	// Upload stores a file and returns its URL.
+	// The name is the file's original name
+	// and is only a hint.
+	Upload(ctx context.Context, name, mimeType string, data []byte) (string, error)
+}
+
+// DirUploader is an Uploader that stores files in a local directory,
+// for serving at BaseURL with its ServeHTTP method.
+// Files are named by a hash of their contents,
+// so uploading the same file twice stores it once.
+type DirUploader struct {
+	Dir     string
+	BaseURL string
+}
+
+var (
+	_ Uploader     = DirUploader{}
+	_ http.Handler = DirUploader{}
+)
+
+var (
+	extRegex          = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+	uploadedNameRegex = regexp.MustCompile(`^[0-9a-f]{64}(\.[a-z0-9]{1,10})?$`)
+)
+
+func (u DirUploader) Upload(_ context.Context, name, _ string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	filename := hex.EncodeToString(sum[:])
+	if ext := filepath.Ext(name); extRegex.MatchString(ext) {
+		filename += strings.ToLower(ext)
+	}
+
+	path := filepath.Join(u.Dir, filename)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err = os.WriteFile(path, data, 0644); err != nil {
+			return "", errors.Wrapf(err, "writing %s", path)
+		}
+	} else if err != nil {
+		return "", errors.Wrapf(err, "checking for %s", path)
+	}
+
+	return strings.TrimSuffix(u.BaseURL, "/") + "/" + filename, nil
+}
+
+// ServeHTTP serves an uploaded file,
+// named by the request's URL path
+// (from which any prefix for BaseURL must be stripped, e.g. with http.StripPrefix).
+// Only the names produced by Upload are served:
+// anything else, including the directory itself, is not found,
+// so nobody can list the files of every tenant.
+func (u DirUploader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	filename := strings.TrimPrefix(req.URL.Path, "/")
+	if !uploadedNameRegex.MatchString(filename) {
+		http.NotFound(w, req)
+		return
+	}
+	http.ServeFile(w, req, filepath.Join(u.Dir, filename))
+}
+
+const (
+	// maxUploadSize is the size of the largest file that is re-hosted.
+	maxUploadSize = 10 * 1024 * 1024
+
+	// maxSnippetSize is the size of the largest text snippet that is also quoted in full.
+	maxSnippetSize = 8 * 1024
+)
+
+// filesToGH renders the files shared in a Slack message as GitHub markdown.
+func (s *Service) filesToGH(ctx context.Context, tenant *Tenant, files []slackevents.File) (string, error) {
+	buf := new(bytes.Buffer)
+	for _, f := range files {
+		if f.Mode == "tombstone" || f.Mode == "hidden_by_limit" {
+			continue
+		}
+
+		title := f.Title
+		if title == "" {
+			title = f.Name
+		}
+		title = ghEscape(title)
+
+		fmt.Fprint(buf, "\n\n")
+
+		var (
+			isImage   = strings.HasPrefix(f.Mimetype, "image/")
+			isSnippet = f.Mode == "snippet" || strings.HasPrefix(f.Mimetype, "text/")
+		)
+		if s.Uploads == nil || f.IsExternal || f.Size > maxUploadSize || !(isImage || isSnippet) {
+			fmt.Fprintf(buf, "[%s](%s)", title, f.Permalink)
+			continue
+		}
+
+		// The size in the event is not trusted to bound the download.
+		data := &limitedBuffer{limit: maxUploadSize}
+		err := tenant.SlackClient().GetFileContext(ctx, f.URLPrivateDownload, data)
+		if errors.Is(err, errTooLarge) {
+			fmt.Fprintf(buf, "[%s](%s)", title, f.Permalink)
+			continue
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "downloading Slack file %s", f.ID)
+		}
+		fileURL, err := s.Uploads.Upload(ctx, f.Name, f.Mimetype, data.Bytes())
+		if err != nil {
+			return "", errors.Wrapf(err, "uploading Slack file %s", f.ID)
+		}
+
+		if isImage {
+			fmt.Fprintf(buf, "![%s](%s)", title, fileURL)
+			continue
+		}
+
+		fmt.Fprintf(buf, "[%s](%s)", title, fileURL)
+		if data.Len() <= maxSnippetSize {
+			fmt.Fprint(buf, "\n\n", ghCodeBlock(f.Filetype, data.String()))
+		}
+	}
+	return buf.String(), nil
+}
+
+var errTooLarge = fmt.Errorf("too large")
+
+// limitedBuffer is a bytes.Buffer that refuses to grow beyond limit bytes,
+// returning errTooLarge instead.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+var backtickRunRegex = regexp.MustCompile("`{3,}")
+
+// ghCodeBlock renders text as a fenced code block in GitHub markdown,
+// using a fence longer than any run of backticks in the text.
+func ghCodeBlock(lang, text string) string {
+	fence := "```"
+	for _, run := range backtickRunRegex.FindAllString(text, -1) {
+		if len(run) >= len(fence) {
+			fence = strings.Repeat("`", len(run)+1)
+		}
+	}
+	if lang == "text" {
+		lang = ""
+	}
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
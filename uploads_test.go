@@ -0,0 +1,165 @@
+package spreche
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestDirUploader(t *testing.T) {
+	ctx := context.Background()
+
+	u := DirUploader{Dir: t.TempDir(), BaseURL: "https://spreche.example.com/uploads/"}
+
+	url1, err := u.Upload(ctx, "Screen Shot.PNG", "image/png", []byte("png data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(url1, "https://spreche.example.com/uploads/") || !strings.HasSuffix(url1, ".png") {
+		t.Errorf("got URL %s", url1)
+	}
+
+	url2, err := u.Upload(ctx, "copy.png", "image/png", []byte("png data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url2 != url1 {
+		t.Errorf("got %s for the same file uploaded again, want %s", url2, url1)
+	}
+
+	url3, err := u.Upload(ctx, "../../etc/passwd", "text/plain", []byte("other data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.TrimPrefix(url3, u.BaseURL), "/") {
+		t.Errorf("got URL %s", url3)
+	}
+
+	entries, err := os.ReadDir(u.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d files, want 2", len(entries))
+	}
+	data, err := os.ReadFile(filepath.Join(u.Dir, filepath.Base(url1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "png data" {
+		t.Errorf("got %q, want %q", data, "png data")
+	}
+
+	for path, want := range map[string]int{
+		"/" + filepath.Base(url1): http.StatusOK,
+		"/":                       http.StatusNotFound,
+		"/../uploads_test.go":     http.StatusNotFound,
+		"/notahash.png":           http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		u.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("serving %s: got status %d, want %d", path, rec.Code, want)
+		}
+		if want == http.StatusOK && rec.Body.String() != "png data" {
+			t.Errorf("serving %s: got %q, want %q", path, rec.Body.String(), "png data")
+		}
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 5}
+	if _, err := b.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Write([]byte("def")); !errors.Is(err, errTooLarge) {
+		t.Errorf("got error %v, want errTooLarge", err)
+	}
+	if b.String() != "abc" {
+		t.Errorf("got %q, want %q", b.String(), "abc")
+	}
+}
+
+func TestFilesToGH(t *testing.T) {
+	s, api := newFakeService(t, "C0PRCHAN")
+
+	ctx := context.Background()
+	tenant := s.Tenants.(fakeTenantStore).tenant
+
+	files := []slackevents.File{{
+		ID:                 "F1",
+		Name:               "screenshot.png",
+		Title:              "Screenshot",
+		Mimetype:           "image/png",
+		URLPrivateDownload: api.URL + "/files/1",
+		Permalink:          "https://example.slack.com/files/F1",
+	}, {
+		ID:                 "F2",
+		Name:               "main.go",
+		Mimetype:           "text/plain",
+		Filetype:           "go",
+		Mode:               "snippet",
+		URLPrivateDownload: api.URL + "/files/2",
+		Permalink:          "https://example.slack.com/files/F2",
+	}, {
+		ID:        "F3",
+		Name:      "design.pdf",
+		Mimetype:  "application/pdf",
+		Permalink: "https://example.slack.com/files/F3",
+	}, {
+		ID:   "F4",
+		Mode: "tombstone",
+	}}
+
+	// With no Uploader, everything is linked.
+	got, err := s.filesToGH(ctx, tenant, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\n\n[Screenshot](https://example.slack.com/files/F1)\n\n[main\\.go](https://example.slack.com/files/F2)\n\n[design\\.pdf](https://example.slack.com/files/F3)"
+	if got != want {
+		t.Errorf("without uploader: got %q, want %q", got, want)
+	}
+
+	s.Uploads = DirUploader{Dir: t.TempDir(), BaseURL: "https://spreche.example.com/uploads"}
+	got, err = s.filesToGH(ctx, tenant, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("GET /files/"); n != 2 {
+		t.Errorf("got %d downloads, want 2", n)
+	}
+	for _, w := range []string{
+		"![Screenshot](https://spreche.example.com/uploads/",
+		".png)",
+		"[main\\.go](https://spreche.example.com/uploads/",
+		".go)\n\n```go\n{}\n```",
+		"[design\\.pdf](https://example.slack.com/files/F3)",
+	} {
+		if !strings.Contains(got, w) {
+			t.Errorf("with uploader: got %q, want it to contain %q", got, w)
+		}
+	}
+}
+
+func TestGHCodeBlock(t *testing.T) {
+	cases := []struct {
+		lang, text, want string
+	}{
+		{"go", "package x\n", "```go\npackage x\n```"},
+		{"text", "hello", "```\nhello\n```"},
+		{"markdown", "````\nx\n````", "`````markdown\n````\nx\n````\n`````"},
+	}
+	for i, tc := range cases {
+		if got := ghCodeBlock(tc.lang, tc.text); got != tc.want {
+			t.Errorf("case %d: got %q, want %q", i+1, got, tc.want)
+		}
+	}
+}